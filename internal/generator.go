@@ -10,7 +10,7 @@ type Expressions map[string]string
 type Statements map[string]string
 
 func writeExpressionVisitorInterface(expressions Expressions, stringBuilder *strings.Builder) {
-	stringBuilder.WriteString("package ast\n\nimport (\n\"github.com/prometheus1400/kel/src/scanner\"\n\"tinygo.org/x/go-llvm\"\n)\ntype VisitExpr interface{\n")
+	stringBuilder.WriteString("package ast\n\nimport (\n\"go/constant\"\n\"github.com/prometheus1400/kel/src/scanner\"\n\"tinygo.org/x/go-llvm\"\n)\ntype VisitExpr interface{\n")
 	for name := range expressions {
 		fmtName := name + "Expr"
 		stringBuilder.WriteString(fmt.Sprintf("\tVisit%s(expr *%s) llvm.Value\n", fmtName, fmtName))
@@ -44,11 +44,48 @@ func writeStatementVisitorInterface(stmts Statements, stringBuilder *strings.Bui
 	stringBuilder.WriteString("type Type struct {\n")
 	stringBuilder.WriteString("\tToken scanner.Token\n")
 	stringBuilder.WriteString("\tIsPointer bool\n")
+	stringBuilder.WriteString("\tIsNullable bool\n")
+	stringBuilder.WriteString("\tIsArray bool\n")
+	stringBuilder.WriteString("\tIsSlice bool\n")
+	stringBuilder.WriteString("\tIsErrorUnion bool\n")
+	stringBuilder.WriteString("\tIsFunction bool\n")
+	stringBuilder.WriteString("\tIsTuple bool\n")
+	stringBuilder.WriteString("\tLength int\n")
+	stringBuilder.WriteString("\tElem *Type\n")
+	stringBuilder.WriteString("\tParams []Type\n")
+	stringBuilder.WriteString("\tReturn *Type\n")
+	stringBuilder.WriteString("\tTypeArgs []Type\n")
+	stringBuilder.WriteString("\tElems []Type\n")
+	stringBuilder.WriteString("\tTypeParam *TypeParam\n")
 	stringBuilder.WriteString("}\n")
 	stringBuilder.WriteString("type Param struct {\n")
 	stringBuilder.WriteString("\tName scanner.Token\n")
 	stringBuilder.WriteString("\tType Type\n")
 	stringBuilder.WriteString("}\n")
+	stringBuilder.WriteString("type Field struct {\n")
+	stringBuilder.WriteString("\tName scanner.Token\n")
+	stringBuilder.WriteString("\tType Type\n")
+	stringBuilder.WriteString("\tIsPublic bool\n")
+	stringBuilder.WriteString("}\n")
+	stringBuilder.WriteString("type Method struct {\n")
+	stringBuilder.WriteString("\tFn *FnStmt\n")
+	stringBuilder.WriteString("\tIsPublic bool\n")
+	stringBuilder.WriteString("}\n")
+	stringBuilder.WriteString("type TypeParam struct {\n")
+	stringBuilder.WriteString("\tName scanner.Token\n")
+	stringBuilder.WriteString("\tBounds []scanner.Token\n")
+	stringBuilder.WriteString("}\n")
+	stringBuilder.WriteString("type MatchArm struct {\n")
+	stringBuilder.WriteString("\tPatterns []Pattern\n")
+	stringBuilder.WriteString("\tBody Stmt\n")
+	stringBuilder.WriteString("\tValue Expr\n")
+	stringBuilder.WriteString("}\n")
+	stringBuilder.WriteString("type Pattern struct {\n")
+	stringBuilder.WriteString("\tToken scanner.Token\n")
+	stringBuilder.WriteString("\tValue Expr\n")
+	stringBuilder.WriteString("\tEnd Expr\n")
+	stringBuilder.WriteString("\tIsWildcard bool\n")
+	stringBuilder.WriteString("}\n")
 	stringBuilder.WriteString("\n")
 }
 
@@ -65,15 +102,33 @@ func writeStatements(stmts Statements, stringBuilder *strings.Builder) {
 func main() {
 	exprString := &strings.Builder{}
 	expressions := Expressions{
-		"Number":     "Value float64",
-		"String":     "Value string",
-		"Char":       "Value int8",
-		"Bool":       "Value bool",
-		"Identifier": "Value scanner.Token",
-		"Binary":     "Left Expr, Operator scanner.Token, Right Expr",
-		"Unary":      "Operator scanner.Token, Right Expr",
-		"Grouping":   "Expression Expr",
-		"Call":       "Callee Expr, Args []Expr",
+		"Number":        "Value constant.Value, Suffix *Type",
+		"String":        "Value string",
+		"Char":          "Value int8",
+		"Bool":          "Value bool",
+		"Identifier":    "Value scanner.Token",
+		"Binary":        "Left Expr, Operator scanner.Token, Right Expr",
+		"Logical":       "Left Expr, Operator scanner.Token, Right Expr",
+		"Unary":         "Operator scanner.Token, Right Expr",
+		"Grouping":      "Expression Expr",
+		"Call":          "Callee Expr, Paren scanner.Token, TypeArgs []Type, Args []Expr",
+		"Assign":        "Target Expr, Operator scanner.Token, Value Expr",
+		"Increment":     "Target Expr, Operator scanner.Token, IsPrefix bool",
+		"Get":           "Object Expr, Name scanner.Token",
+		"ArrayLiteral":  "Bracket scanner.Token, Elements []Expr",
+		"Index":         "Object Expr, Bracket scanner.Token, Index Expr",
+		"Slice":         "Object Expr, Bracket scanner.Token, Start Expr, End Expr",
+		"StructLiteral": "Name scanner.Token, TypeArgs []Type, Fields []scanner.Token, Values []Expr",
+		"Error":         "Keyword scanner.Token, Code Expr",
+		"Try":           "Keyword scanner.Token, Expression Expr",
+		"Catch":         "Expression Expr, Keyword scanner.Token, Fallback Expr",
+		"Nil":           "Keyword scanner.Token",
+		"Unwrap":        "Expression Expr, Operator scanner.Token",
+		"Cast":          "Expression Expr, Keyword scanner.Token, Type Type",
+		"Interpolation": "Start scanner.Token, Strings []string, Values []Expr",
+		"Function":      "Keyword scanner.Token, Params []Param, Body Stmt, Return Type",
+		"Match":         "Keyword scanner.Token, Subject Expr, Arms []MatchArm",
+		"Tuple":         "Paren scanner.Token, Elements []Expr",
 	}
 	writeExpressionVisitorInterface(expressions, exprString)
 	writeExpressions(expressions, exprString)
@@ -86,12 +141,22 @@ func main() {
 	stmtString := &strings.Builder{}
 	stmts := Statements{
 		"Block":      "Body []Stmt",
-		"Var":        "Name scanner.Token, Type Type, Initializer Expr",
-		"Fn":         "Name scanner.Token, Params []Param, Body Stmt, Return Type",
+		"Var":        "Name scanner.Token, Type Type, Initializer Expr, IsConst bool",
+		"Fn":         "Name scanner.Token, TypeParams []TypeParam, Params []Param, Body Stmt, Return Type",
 		"Print":      "Expression Expr",
 		"Expression": "Expression Expr",
-		"Return":     "Expression Expr",
-		"If":         "IfCondition Expr, IfBlock Stmt, ElifConditions []Expr, ElifBlocks []Stmt, ElseBlock Stmt",
+		"Return":     "Keyword scanner.Token, Expression Expr",
+		"If":         "Keyword scanner.Token, IfCondition Expr, IfBlock Stmt, ElifKeywords []scanner.Token, ElifConditions []Expr, ElifBlocks []Stmt, ElseBlock Stmt",
+		"While":      "Keyword scanner.Token, Condition Expr, Body Stmt",
+		"For":        "Keyword scanner.Token, Initializer Stmt, Condition Expr, Increment Expr, Body Stmt",
+		"ForRange":   "Variable scanner.Token, Start Expr, End Expr, Body Stmt",
+		"Struct":     "Name scanner.Token, TypeParams []TypeParam, Implements []scanner.Token, Fields []Field, Methods []Method",
+		"Enum":       "Name scanner.Token, Variants []scanner.Token",
+		"Interface":  "Name scanner.Token, Methods []*FnStmt",
+		"Match":      "Keyword scanner.Token, Subject Expr, Arms []MatchArm",
+		"Unpack":     "Keyword scanner.Token, Names []scanner.Token, Initializer Expr, IsConst bool",
+		"Break":      "Keyword scanner.Token",
+		"Continue":   "Keyword scanner.Token",
 	}
 	writeStatementVisitorInterface(stmts, stmtString)
 	writeStatements(stmts, stmtString)
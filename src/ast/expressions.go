@@ -1,31 +1,45 @@
 package ast
 
 import (
+"go/constant"
 "github.com/prometheus1400/kel/src/scanner"
 "tinygo.org/x/go-llvm"
 )
 type VisitExpr interface{
+	VisitTupleExpr(expr *TupleExpr) llvm.Value
+	VisitMatchExpr(expr *MatchExpr) llvm.Value
 	VisitStringExpr(expr *StringExpr) llvm.Value
-	VisitGroupingExpr(expr *GroupingExpr) llvm.Value
-	VisitNumberExpr(expr *NumberExpr) llvm.Value
 	VisitCharExpr(expr *CharExpr) llvm.Value
+	VisitLogicalExpr(expr *LogicalExpr) llvm.Value
+	VisitGetExpr(expr *GetExpr) llvm.Value
+	VisitIndexExpr(expr *IndexExpr) llvm.Value
+	VisitStructLiteralExpr(expr *StructLiteralExpr) llvm.Value
+	VisitTryExpr(expr *TryExpr) llvm.Value
+	VisitInterpolationExpr(expr *InterpolationExpr) llvm.Value
+	VisitNumberExpr(expr *NumberExpr) llvm.Value
 	VisitBoolExpr(expr *BoolExpr) llvm.Value
 	VisitIdentifierExpr(expr *IdentifierExpr) llvm.Value
-	VisitBinaryExpr(expr *BinaryExpr) llvm.Value
 	VisitUnaryExpr(expr *UnaryExpr) llvm.Value
+	VisitGroupingExpr(expr *GroupingExpr) llvm.Value
+	VisitAssignExpr(expr *AssignExpr) llvm.Value
+	VisitArrayLiteralExpr(expr *ArrayLiteralExpr) llvm.Value
+	VisitErrorExpr(expr *ErrorExpr) llvm.Value
+	VisitBinaryExpr(expr *BinaryExpr) llvm.Value
+	VisitIncrementExpr(expr *IncrementExpr) llvm.Value
+	VisitSliceExpr(expr *SliceExpr) llvm.Value
+	VisitCatchExpr(expr *CatchExpr) llvm.Value
+	VisitUnwrapExpr(expr *UnwrapExpr) llvm.Value
+	VisitCastExpr(expr *CastExpr) llvm.Value
 	VisitCallExpr(expr *CallExpr) llvm.Value
+	VisitNilExpr(expr *NilExpr) llvm.Value
+	VisitFunctionExpr(expr *FunctionExpr) llvm.Value
 }
-type StringExpr struct {
-	Value string
-}
-func (e *StringExpr) expr() {}
-func (e *StringExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitStringExpr(e)}
-
-type GroupingExpr struct {
-	Expression Expr
+type ErrorExpr struct {
+	Keyword scanner.Token
+	Code Expr
 }
-func (e *GroupingExpr) expr() {}
-func (e *GroupingExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitGroupingExpr(e)}
+func (e *ErrorExpr) expr() {}
+func (e *ErrorExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitErrorExpr(e)}
 
 type BinaryExpr struct {
 	Left Expr
@@ -35,25 +49,75 @@ type BinaryExpr struct {
 func (e *BinaryExpr) expr() {}
 func (e *BinaryExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitBinaryExpr(e)}
 
-type UnaryExpr struct {
+type IncrementExpr struct {
+	Target Expr
 	Operator scanner.Token
-	Right Expr
+	IsPrefix bool
 }
-func (e *UnaryExpr) expr() {}
-func (e *UnaryExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitUnaryExpr(e)}
+func (e *IncrementExpr) expr() {}
+func (e *IncrementExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitIncrementExpr(e)}
+
+type SliceExpr struct {
+	Object Expr
+	Bracket scanner.Token
+	Start Expr
+	End Expr
+}
+func (e *SliceExpr) expr() {}
+func (e *SliceExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitSliceExpr(e)}
+
+type CatchExpr struct {
+	Expression Expr
+	Keyword scanner.Token
+	Fallback Expr
+}
+func (e *CatchExpr) expr() {}
+func (e *CatchExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitCatchExpr(e)}
+
+type UnwrapExpr struct {
+	Expression Expr
+	Operator scanner.Token
+}
+func (e *UnwrapExpr) expr() {}
+func (e *UnwrapExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitUnwrapExpr(e)}
+
+type CastExpr struct {
+	Expression Expr
+	Keyword scanner.Token
+	Type Type
+}
+func (e *CastExpr) expr() {}
+func (e *CastExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitCastExpr(e)}
 
 type CallExpr struct {
 	Callee Expr
+	Paren scanner.Token
+	TypeArgs []Type
 	Args []Expr
 }
 func (e *CallExpr) expr() {}
 func (e *CallExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitCallExpr(e)}
 
-type NumberExpr struct {
-	Value float64
+type NilExpr struct {
+	Keyword scanner.Token
 }
-func (e *NumberExpr) expr() {}
-func (e *NumberExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitNumberExpr(e)}
+func (e *NilExpr) expr() {}
+func (e *NilExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitNilExpr(e)}
+
+type FunctionExpr struct {
+	Keyword scanner.Token
+	Params []Param
+	Body Stmt
+	Return Type
+}
+func (e *FunctionExpr) expr() {}
+func (e *FunctionExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitFunctionExpr(e)}
+
+type StringExpr struct {
+	Value string
+}
+func (e *StringExpr) expr() {}
+func (e *StringExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitStringExpr(e)}
 
 type CharExpr struct {
 	Value int8
@@ -61,6 +125,60 @@ type CharExpr struct {
 func (e *CharExpr) expr() {}
 func (e *CharExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitCharExpr(e)}
 
+type LogicalExpr struct {
+	Left Expr
+	Operator scanner.Token
+	Right Expr
+}
+func (e *LogicalExpr) expr() {}
+func (e *LogicalExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitLogicalExpr(e)}
+
+type GetExpr struct {
+	Object Expr
+	Name scanner.Token
+}
+func (e *GetExpr) expr() {}
+func (e *GetExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitGetExpr(e)}
+
+type IndexExpr struct {
+	Object Expr
+	Bracket scanner.Token
+	Index Expr
+}
+func (e *IndexExpr) expr() {}
+func (e *IndexExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitIndexExpr(e)}
+
+type StructLiteralExpr struct {
+	Name scanner.Token
+	TypeArgs []Type
+	Fields []scanner.Token
+	Values []Expr
+}
+func (e *StructLiteralExpr) expr() {}
+func (e *StructLiteralExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitStructLiteralExpr(e)}
+
+type TryExpr struct {
+	Keyword scanner.Token
+	Expression Expr
+}
+func (e *TryExpr) expr() {}
+func (e *TryExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitTryExpr(e)}
+
+type InterpolationExpr struct {
+	Start scanner.Token
+	Strings []string
+	Values []Expr
+}
+func (e *InterpolationExpr) expr() {}
+func (e *InterpolationExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitInterpolationExpr(e)}
+
+type NumberExpr struct {
+	Value constant.Value
+	Suffix *Type
+}
+func (e *NumberExpr) expr() {}
+func (e *NumberExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitNumberExpr(e)}
+
 type BoolExpr struct {
 	Value bool
 }
@@ -73,3 +191,46 @@ type IdentifierExpr struct {
 func (e *IdentifierExpr) expr() {}
 func (e *IdentifierExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitIdentifierExpr(e)}
 
+type UnaryExpr struct {
+	Operator scanner.Token
+	Right Expr
+}
+func (e *UnaryExpr) expr() {}
+func (e *UnaryExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitUnaryExpr(e)}
+
+type GroupingExpr struct {
+	Expression Expr
+}
+func (e *GroupingExpr) expr() {}
+func (e *GroupingExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitGroupingExpr(e)}
+
+type AssignExpr struct {
+	Target Expr
+	Operator scanner.Token
+	Value Expr
+}
+func (e *AssignExpr) expr() {}
+func (e *AssignExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitAssignExpr(e)}
+
+type ArrayLiteralExpr struct {
+	Bracket scanner.Token
+	Elements []Expr
+}
+func (e *ArrayLiteralExpr) expr() {}
+func (e *ArrayLiteralExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitArrayLiteralExpr(e)}
+
+type MatchExpr struct {
+	Keyword scanner.Token
+	Subject Expr
+	Arms []MatchArm
+}
+func (e *MatchExpr) expr() {}
+func (e *MatchExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitMatchExpr(e)}
+
+type TupleExpr struct {
+	Paren scanner.Token
+	Elements []Expr
+}
+func (e *TupleExpr) expr() {}
+func (e *TupleExpr) Visit(visitor VisitExpr) llvm.Value {return visitor.VisitTupleExpr(e)}
+
@@ -0,0 +1,46 @@
+package ast
+
+import "reflect"
+
+// Inspect calls visit for every Stmt and Expr in node, including node itself, parents before their children.
+// the children of a node are skipped when visit returns false for it
+func Inspect(node any, visit func(node any) bool) {
+	inspect(reflect.ValueOf(node), visit)
+}
+
+var (
+	stmtType = reflect.TypeOf((*Stmt)(nil)).Elem()
+	exprType = reflect.TypeOf((*Expr)(nil)).Elem()
+	astPath  = reflect.TypeOf(Type{}).PkgPath()
+)
+
+func inspect(value reflect.Value, visit func(node any) bool) {
+	switch value.Kind() {
+	case reflect.Interface:
+		if !value.IsNil() {
+			inspect(value.Elem(), visit)
+		}
+	case reflect.Pointer:
+		if value.IsNil() || value.Type().Elem().PkgPath() != astPath {
+			return
+		}
+		if value.Type().Implements(stmtType) || value.Type().Implements(exprType) {
+			if !visit(value.Interface()) {
+				return
+			}
+		}
+		inspect(value.Elem(), visit)
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			inspect(value.Index(i), visit)
+		}
+	case reflect.Struct:
+		// tokens and constants hold no nodes
+		if value.Type().PkgPath() != astPath {
+			return
+		}
+		for i := 0; i < value.NumField(); i++ {
+			inspect(value.Field(i), visit)
+		}
+	}
+}
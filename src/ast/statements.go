@@ -5,33 +5,70 @@ import (
 )
 
 type VisitStmt interface{
+	VisitUnpackStmt(stmt *UnpackStmt)
+	VisitMatchStmt(stmt *MatchStmt)
+	VisitInterfaceStmt(stmt *InterfaceStmt)
+	VisitContinueStmt(stmt *ContinueStmt)
+	VisitBlockStmt(stmt *BlockStmt)
+	VisitVarStmt(stmt *VarStmt)
 	VisitPrintStmt(stmt *PrintStmt)
 	VisitExpressionStmt(stmt *ExpressionStmt)
+	VisitWhileStmt(stmt *WhileStmt)
+	VisitForStmt(stmt *ForStmt)
+	VisitForRangeStmt(stmt *ForRangeStmt)
+	VisitEnumStmt(stmt *EnumStmt)
+	VisitFnStmt(stmt *FnStmt)
 	VisitReturnStmt(stmt *ReturnStmt)
 	VisitIfStmt(stmt *IfStmt)
-	VisitBlockStmt(stmt *BlockStmt)
-	VisitVarStmt(stmt *VarStmt)
-	VisitFnStmt(stmt *FnStmt)
+	VisitStructStmt(stmt *StructStmt)
+	VisitBreakStmt(stmt *BreakStmt)
 }
 
 type Type struct {
 	Token scanner.Token
 	IsPointer bool
+	IsNullable bool
+	IsArray bool
+	IsSlice bool
+	IsErrorUnion bool
+	IsFunction bool
+	IsTuple bool
+	Length int
+	Elem *Type
+	Params []Type
+	Return *Type
+	TypeArgs []Type
+	Elems []Type
+	TypeParam *TypeParam
 }
 type Param struct {
 	Name scanner.Token
 	Type Type
 }
-
-type IfStmt struct {
-	IfCondition Expr
-	IfBlock Stmt
-	ElifConditions []Expr
-	ElifBlocks []Stmt
-	ElseBlock Stmt
+type Field struct {
+	Name scanner.Token
+	Type Type
+	IsPublic bool
+}
+type Method struct {
+	Fn *FnStmt
+	IsPublic bool
+}
+type TypeParam struct {
+	Name scanner.Token
+	Bounds []scanner.Token
+}
+type MatchArm struct {
+	Patterns []Pattern
+	Body Stmt
+	Value Expr
+}
+type Pattern struct {
+	Token scanner.Token
+	Value Expr
+	End Expr
+	IsWildcard bool
 }
-func (e *IfStmt) stmt() {}
-func (e *IfStmt) Visit(visitor VisitStmt) {visitor.VisitIfStmt(e)}
 
 type BlockStmt struct {
 	Body []Stmt
@@ -43,19 +80,11 @@ type VarStmt struct {
 	Name scanner.Token
 	Type Type
 	Initializer Expr
+	IsConst bool
 }
 func (e *VarStmt) stmt() {}
 func (e *VarStmt) Visit(visitor VisitStmt) {visitor.VisitVarStmt(e)}
 
-type FnStmt struct {
-	Name scanner.Token
-	Params []Param
-	Body Stmt
-	Return Type
-}
-func (e *FnStmt) stmt() {}
-func (e *FnStmt) Visit(visitor VisitStmt) {visitor.VisitFnStmt(e)}
-
 type PrintStmt struct {
 	Expression Expr
 }
@@ -68,9 +97,112 @@ type ExpressionStmt struct {
 func (e *ExpressionStmt) stmt() {}
 func (e *ExpressionStmt) Visit(visitor VisitStmt) {visitor.VisitExpressionStmt(e)}
 
+type WhileStmt struct {
+	Keyword scanner.Token
+	Condition Expr
+	Body Stmt
+}
+func (e *WhileStmt) stmt() {}
+func (e *WhileStmt) Visit(visitor VisitStmt) {visitor.VisitWhileStmt(e)}
+
+type ForStmt struct {
+	Keyword scanner.Token
+	Initializer Stmt
+	Condition Expr
+	Increment Expr
+	Body Stmt
+}
+func (e *ForStmt) stmt() {}
+func (e *ForStmt) Visit(visitor VisitStmt) {visitor.VisitForStmt(e)}
+
+type ForRangeStmt struct {
+	Variable scanner.Token
+	Start Expr
+	End Expr
+	Body Stmt
+}
+func (e *ForRangeStmt) stmt() {}
+func (e *ForRangeStmt) Visit(visitor VisitStmt) {visitor.VisitForRangeStmt(e)}
+
+type InterfaceStmt struct {
+	Name scanner.Token
+	Methods []*FnStmt
+}
+func (e *InterfaceStmt) stmt() {}
+func (e *InterfaceStmt) Visit(visitor VisitStmt) {visitor.VisitInterfaceStmt(e)}
+
+type MatchStmt struct {
+	Keyword scanner.Token
+	Subject Expr
+	Arms []MatchArm
+}
+func (e *MatchStmt) stmt() {}
+func (e *MatchStmt) Visit(visitor VisitStmt) {visitor.VisitMatchStmt(e)}
+
+type UnpackStmt struct {
+	Keyword scanner.Token
+	Names []scanner.Token
+	Initializer Expr
+	IsConst bool
+}
+func (e *UnpackStmt) stmt() {}
+func (e *UnpackStmt) Visit(visitor VisitStmt) {visitor.VisitUnpackStmt(e)}
+
+type EnumStmt struct {
+	Name scanner.Token
+	Variants []scanner.Token
+}
+func (e *EnumStmt) stmt() {}
+func (e *EnumStmt) Visit(visitor VisitStmt) {visitor.VisitEnumStmt(e)}
+
+type FnStmt struct {
+	Name scanner.Token
+	TypeParams []TypeParam
+	Params []Param
+	Body Stmt
+	Return Type
+}
+func (e *FnStmt) stmt() {}
+func (e *FnStmt) Visit(visitor VisitStmt) {visitor.VisitFnStmt(e)}
+
 type ReturnStmt struct {
+	Keyword scanner.Token
 	Expression Expr
 }
 func (e *ReturnStmt) stmt() {}
 func (e *ReturnStmt) Visit(visitor VisitStmt) {visitor.VisitReturnStmt(e)}
 
+type IfStmt struct {
+	Keyword scanner.Token
+	IfCondition Expr
+	IfBlock Stmt
+	ElifKeywords []scanner.Token
+	ElifConditions []Expr
+	ElifBlocks []Stmt
+	ElseBlock Stmt
+}
+func (e *IfStmt) stmt() {}
+func (e *IfStmt) Visit(visitor VisitStmt) {visitor.VisitIfStmt(e)}
+
+type StructStmt struct {
+	Name scanner.Token
+	TypeParams []TypeParam
+	Implements []scanner.Token
+	Fields []Field
+	Methods []Method
+}
+func (e *StructStmt) stmt() {}
+func (e *StructStmt) Visit(visitor VisitStmt) {visitor.VisitStructStmt(e)}
+
+type BreakStmt struct {
+	Keyword scanner.Token
+}
+func (e *BreakStmt) stmt() {}
+func (e *BreakStmt) Visit(visitor VisitStmt) {visitor.VisitBreakStmt(e)}
+
+type ContinueStmt struct {
+	Keyword scanner.Token
+}
+func (e *ContinueStmt) stmt() {}
+func (e *ContinueStmt) Visit(visitor VisitStmt) {visitor.VisitContinueStmt(e)}
+
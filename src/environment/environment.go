@@ -28,3 +28,15 @@ func (s *Environment[T]) Get(name string) (T, bool) {
 func (s *Environment[T]) Set(name string, value T) {
 	s.table[name] = value
 }
+
+// updates name in the closest environment that defines it
+func (s *Environment[T]) Assign(name string, value T) bool {
+	if _, exists := s.table[name]; exists {
+		s.table[name] = value
+		return true
+	}
+	if s.parentEnvironment != nil {
+		return s.parentEnvironment.Assign(name, value)
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package llvm
+
+import (
+	"fmt"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// slices are lowered to { ptr, len, cap }. other slices can share the memory behind a slice, so it's never
+// reallocated or freed and growing a slice always copies it into a fresh allocation. a cap of 0 marks a view
+// (s[a..b]) that can't be appended to in place
+const (
+	slicePtrIndex = 0
+	sliceLenIndex = 1
+	sliceCapIndex = 2
+)
+
+func (g *IRGenerator) sliceType(elem ast.Type) llvm.Type {
+	elemPtr := llvm.PointerType(g.llvmTypeFromAstType(elem), 0)
+	return g.ctx.StructType([]llvm.Type{elemPtr, g.ctx.Int64Type(), g.ctx.Int64Type()}, false)
+}
+
+func (g *IRGenerator) builtinCall(expr *ast.CallExpr, name scanner.Token) llvm.Value {
+	switch name.Lexeme {
+	case "len":
+		return g.builtinLen(expr, name)
+	case "append":
+		return g.builtinAppend(expr, name)
+	default:
+		panic(fmt.Sprintf("unknown builtin '%s' (line %d)", name.Lexeme, name.Line))
+	}
+}
+
+// len(x) works on arrays, slices and strings
+func (g *IRGenerator) builtinLen(expr *ast.CallExpr, name scanner.Token) llvm.Value {
+	if len(expr.Args) != 1 {
+		panic(fmt.Sprintf("len expects 1 argument but got %d (line %d)", len(expr.Args), name.Line))
+	}
+	value := g.evaluate(expr.Args[0])
+	valueType := g.typeOf(expr.Args[0])
+	var length llvm.Value
+	switch {
+	case valueType.IsArray && !valueType.IsPointer:
+		length = llvm.ConstInt(g.ctx.Int64Type(), uint64(valueType.Length), false)
+	case valueType.IsSlice && !valueType.IsPointer:
+		length = g.builder.CreateExtractValue(value, sliceLenIndex, "len")
+	case isString(valueType):
+		length = g.builder.CreateExtractValue(value, stringLenIndex, "len")
+	default:
+		panic(fmt.Sprintf("len expects an array, slice or string (line %d)", name.Line))
+	}
+	g.exprTypes[expr] = primitiveType("i64")
+	return length
+}
+
+// append(s, v) returns a slice with v added to the end, growing the backing memory when it's full
+func (g *IRGenerator) builtinAppend(expr *ast.CallExpr, name scanner.Token) llvm.Value {
+	if len(expr.Args) != 2 {
+		panic(fmt.Sprintf("append expects 2 arguments but got %d (line %d)", len(expr.Args), name.Line))
+	}
+	slice := g.evaluate(expr.Args[0])
+	sliceType := g.typeOf(expr.Args[0])
+	if !sliceType.IsSlice || sliceType.IsPointer {
+		panic(fmt.Sprintf("append expects a slice as its first argument (line %d)", name.Line))
+	}
+	value := g.evaluateAs(expr.Args[1], *sliceType.Elem, name.Line)
+	elemType := g.llvmTypeFromAstType(*sliceType.Elem)
+	if value.Type() != elemType {
+		panic(fmt.Sprintf("cannot append value of the wrong type to slice (line %d)", name.Line))
+	}
+
+	i64 := g.ctx.Int64Type()
+	ptr := g.builder.CreateExtractValue(slice, slicePtrIndex, "ptr")
+	length := g.builder.CreateExtractValue(slice, sliceLenIndex, "len")
+	capacity := g.builder.CreateExtractValue(slice, sliceCapIndex, "cap")
+
+	startBlock := g.builder.GetInsertBlock()
+	growBlock := llvm.AddBasicBlock(g.currentFunction, "appendGrow")
+	storeBlock := llvm.AddBasicBlock(g.currentFunction, "appendStore")
+
+	isFull := g.builder.CreateICmp(llvm.IntUGE, length, capacity, "isFull")
+	g.builder.CreateCondBr(isFull, growBlock, storeBlock)
+
+	// double the capacity, starting from at least 4 elements
+	g.builder.SetInsertPointAtEnd(growBlock)
+	isView := g.builder.CreateICmp(llvm.IntEQ, capacity, llvm.ConstInt(i64, 0, false), "isView")
+	base := g.builder.CreateSelect(isView, length, capacity, "")
+	doubled := g.builder.CreateMul(base, llvm.ConstInt(i64, 2, false), "")
+	tooSmall := g.builder.CreateICmp(llvm.IntULT, doubled, llvm.ConstInt(i64, 4, false), "")
+	newCapacity := g.builder.CreateSelect(tooSmall, llvm.ConstInt(i64, 4, false), doubled, "newCap")
+	grownPtr := g.copyToHeap(elemType, ptr, length, newCapacity)
+	g.builder.CreateBr(storeBlock)
+	growEnd := g.builder.GetInsertBlock()
+
+	g.builder.SetInsertPointAtEnd(storeBlock)
+	finalPtr := g.builder.CreatePHI(ptr.Type(), "")
+	finalPtr.AddIncoming([]llvm.Value{ptr, grownPtr}, []llvm.BasicBlock{startBlock, growEnd})
+	finalCapacity := g.builder.CreatePHI(i64, "")
+	finalCapacity.AddIncoming([]llvm.Value{capacity, newCapacity}, []llvm.BasicBlock{startBlock, growEnd})
+	elemPtr := g.builder.CreateInBoundsGEP(elemType, finalPtr, []llvm.Value{length}, "")
+	g.builder.CreateStore(value, elemPtr)
+
+	newLength := g.builder.CreateAdd(length, llvm.ConstInt(i64, 1, false), "")
+	result := g.buildSlice(slice.Type(), finalPtr, newLength, finalCapacity)
+	g.exprTypes[expr] = sliceType
+	return result
+}
+
+// s[start..end] produces a view sharing the slice's memory. arr[start..end] copies the elements to the heap
+// instead, a view into the array would dangle once the function it lives in returns
+func (g *IRGenerator) VisitSliceExpr(expr *ast.SliceExpr) llvm.Value {
+	basePtr, baseType := g.evaluateAggregateAddress(expr.Object, expr.Bracket.Line)
+	var elemPtr llvm.Value
+	var length llvm.Value
+	switch {
+	case baseType.IsArray:
+		zero := llvm.ConstInt(g.ctx.Int64Type(), 0, false)
+		elemPtr = g.builder.CreateInBoundsGEP(g.llvmTypeFromAstType(baseType), basePtr, []llvm.Value{zero, zero}, "")
+		length = llvm.ConstInt(g.ctx.Int64Type(), uint64(baseType.Length), false)
+	case baseType.IsSlice:
+		slice := g.builder.CreateLoad(g.llvmTypeFromAstType(baseType), basePtr, "")
+		elemPtr = g.builder.CreateExtractValue(slice, slicePtrIndex, "ptr")
+		length = g.builder.CreateExtractValue(slice, sliceLenIndex, "len")
+	default:
+		panic(fmt.Sprintf("can only slice arrays and slices (line %d)", expr.Bracket.Line))
+	}
+
+	start := llvm.ConstInt(g.ctx.Int64Type(), 0, false)
+	if expr.Start != nil {
+		start = g.evaluateIndex(expr.Start, expr.Bracket)
+	}
+	end := length
+	if expr.End != nil {
+		end = g.evaluateIndex(expr.End, expr.Bracket)
+	}
+	startInRange := g.builder.CreateICmp(llvm.IntULE, start, end, "")
+	endInRange := g.builder.CreateICmp(llvm.IntULE, end, length, "")
+	g.trapUnless(g.builder.CreateAnd(startInRange, endInRange, ""), fmt.Sprintf("slice bounds out of range (line %d)", expr.Bracket.Line))
+
+	elemType := *baseType.Elem
+	viewPtr := g.builder.CreateInBoundsGEP(g.llvmTypeFromAstType(elemType), elemPtr, []llvm.Value{start}, "")
+	viewLength := g.builder.CreateSub(end, start, "")
+	capacity := llvm.ConstInt(g.ctx.Int64Type(), 0, false)
+	if baseType.IsArray {
+		viewPtr = g.copyToHeap(g.llvmTypeFromAstType(elemType), viewPtr, viewLength, viewLength)
+		capacity = viewLength
+	}
+	g.exprTypes[expr] = ast.Type{Token: expr.Bracket, IsSlice: true, Elem: &elemType}
+	return g.buildSlice(g.sliceType(elemType), viewPtr, viewLength, capacity)
+}
+
+// address of s[i] after checking that i is within the slice's length
+func (g *IRGenerator) sliceElementAddress(expr *ast.IndexExpr, slicePtr llvm.Value, sliceType ast.Type) llvm.Value {
+	slice := g.builder.CreateLoad(g.llvmTypeFromAstType(sliceType), slicePtr, "")
+	ptr := g.builder.CreateExtractValue(slice, slicePtrIndex, "ptr")
+	length := g.builder.CreateExtractValue(slice, sliceLenIndex, "len")
+	index := g.evaluateIndex(expr.Index, expr.Bracket)
+	inBounds := g.builder.CreateICmp(llvm.IntULT, index, length, "inBounds")
+	g.trapUnless(inBounds, fmt.Sprintf("index out of bounds (line %d)", expr.Bracket.Line))
+	g.exprTypes[expr] = *sliceType.Elem
+	return g.builder.CreateInBoundsGEP(g.llvmTypeFromAstType(*sliceType.Elem), ptr, []llvm.Value{index}, "elementPtr")
+}
+
+// copies length elements from ptr into a new allocation with room for capacity elements
+func (g *IRGenerator) copyToHeap(elemType llvm.Type, ptr llvm.Value, length llvm.Value, capacity llvm.Value) llvm.Value {
+	elemSize := llvm.SizeOf(elemType)
+	malloc := g.module.NamedFunction("malloc")
+	newPtr := g.builder.CreateCall(malloc.GlobalValueType(), malloc, []llvm.Value{g.builder.CreateMul(capacity, elemSize, "")}, "")
+	memcpy := g.module.NamedFunction("memcpy")
+	usedBytes := g.builder.CreateMul(length, elemSize, "")
+	g.builder.CreateCall(memcpy.GlobalValueType(), memcpy, []llvm.Value{newPtr, g.toBytePtr(ptr), usedBytes}, "")
+	return g.builder.CreateBitCast(newPtr, ptr.Type(), "")
+}
+
+func (g *IRGenerator) buildSlice(sliceType llvm.Type, ptr llvm.Value, length llvm.Value, capacity llvm.Value) llvm.Value {
+	slice := llvm.Undef(sliceType)
+	slice = g.builder.CreateInsertValue(slice, ptr, slicePtrIndex, "")
+	slice = g.builder.CreateInsertValue(slice, length, sliceLenIndex, "")
+	slice = g.builder.CreateInsertValue(slice, capacity, sliceCapIndex, "slice")
+	return slice
+}
+
+func (g *IRGenerator) toBytePtr(ptr llvm.Value) llvm.Value {
+	return g.builder.CreateBitCast(ptr, llvm.PointerType(g.ctx.Int8Type(), 0), "")
+}
@@ -0,0 +1,202 @@
+package llvm
+
+import (
+	"fmt"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/environment"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// function values are lowered to { fn, env }. fn takes env as a hidden first param before the declared ones,
+// env points to the values the function captured where it was created and is null if it captured nothing
+const (
+	closureFnIndex  = 0
+	closureEnvIndex = 1
+)
+
+// the anonymous function whose body is being generated and the variables it captured so far
+type closureInfo struct {
+	env      *environment.Environment[variable] // the function's outermost scope, where captured variables are defined
+	captures []scanner.Token
+}
+
+func (g *IRGenerator) closureType() llvm.Type {
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	return g.ctx.StructType([]llvm.Type{bytePtr, bytePtr}, false)
+}
+
+// the llvm signature of the function behind a function value of the given type
+func (g *IRGenerator) closureFnType(fnType ast.Type) llvm.Type {
+	paramTypes := []llvm.Type{llvm.PointerType(g.ctx.Int8Type(), 0)}
+	for _, param := range fnType.Params {
+		paramTypes = append(paramTypes, g.llvmTypeFromAstType(param))
+	}
+	return llvm.FunctionType(g.llvmTypeFromAstType(*fnType.Return), paramTypes, false)
+}
+
+func functionType(keyword scanner.Token, params []ast.Param, returnType ast.Type) ast.Type {
+	paramTypes := make([]ast.Type, 0)
+	for _, param := range params {
+		paramTypes = append(paramTypes, param.Type)
+	}
+	return ast.Type{Token: keyword, IsFunction: true, Params: paramTypes, Return: &returnType}
+}
+
+func (g *IRGenerator) VisitFunctionExpr(expr *ast.FunctionExpr) llvm.Value {
+	params, returnType := g.resolveParams(expr.Params), g.resolveType(expr.Return)
+	fnType := functionType(expr.Keyword, params, returnType)
+	name := "fn"
+	if !g.currentFunction.IsNil() {
+		name = g.currentFunction.Name() + ".fn"
+	}
+	fn := llvm.AddFunction(g.module, name, g.closureFnType(fnType))
+	fn.SetLinkage(llvm.InternalLinkage)
+
+	// the body is generated on its own and then we come back to where the function value is created
+	currentBlock := g.builder.GetInsertBlock()
+	prevFunction, prevReturn, prevLoops, prevClosure, prevEnv, prevCaptured := g.currentFunction, g.currentReturn, g.loops, g.closure, g.environment, g.captured
+	g.closure = &closureInfo{env: environment.NewEnvironment[variable](prevEnv)}
+	g.environment = g.closure.env
+	g.loops = nil
+	g.generateBody(fn, expr.Keyword, params, 1, returnType, expr.Body)
+	captures := g.closure.captures
+	g.currentFunction, g.currentReturn, g.loops, g.closure, g.environment, g.captured = prevFunction, prevReturn, prevLoops, prevClosure, prevEnv, prevCaptured
+	if !currentBlock.IsNil() {
+		g.builder.SetInsertPointAtEnd(currentBlock)
+	}
+
+	g.exprTypes[expr] = fnType
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	fnPtr := llvm.ConstBitCast(fn, bytePtr)
+	if len(captures) == 0 {
+		return g.ctx.ConstStruct([]llvm.Value{fnPtr, llvm.ConstPointerNull(bytePtr)}, false)
+	}
+	closure := g.builder.CreateInsertValue(llvm.Undef(g.closureType()), fnPtr, closureFnIndex, "")
+	return g.builder.CreateInsertValue(closure, g.closureEnv(captures), closureEnvIndex, "closure")
+}
+
+// names used inside of the anonymous functions in body. locals with these names are kept on the heap instead of
+// the stack, so a closure can capture them by reference and keep using them after the function returns
+func capturedNames(body ast.Stmt) map[string]bool {
+	names := make(map[string]bool)
+	ast.Inspect(body, func(node any) bool {
+		fn, isFunction := node.(*ast.FunctionExpr)
+		if !isFunction {
+			return true
+		}
+		ast.Inspect(fn.Body, func(node any) bool {
+			if identifier, ok := node.(*ast.IdentifierExpr); ok {
+				names[identifier.Value.Lexeme] = true
+			}
+			return true
+		})
+		return false
+	})
+	return names
+}
+
+// a stack slot for a local, or a slot on the heap when an anonymous function may capture it
+func (g *IRGenerator) createLocal(type_ llvm.Type, name string) (llvm.Value, bool) {
+	if !g.captured[name] {
+		return g.createEntryAlloca(type_, name), false
+	}
+	malloc := g.module.NamedFunction("malloc")
+	box := g.builder.CreateCall(malloc.GlobalValueType(), malloc, []llvm.Value{llvm.SizeOf(type_)}, "")
+	return g.builder.CreateBitCast(box, llvm.PointerType(type_, 0), name), true
+}
+
+// a local of an enclosing function used inside of an anonymous function is captured by reference: the env
+// holds the address of the local, so the function and every closure capturing it see each other's assignments
+func (g *IRGenerator) capture(name scanner.Token, captured variable) variable {
+	if g.closure == nil {
+		panic(fmt.Sprintf("cannot use '%s' from the enclosing function, only anonymous functions can capture variables (line %d)", name.Lexeme, name.Line))
+	}
+	index := len(g.closure.captures)
+	g.closure.captures = append(g.closure.captures, name)
+
+	// env is an array holding the address of each captured local, read once at the start of the function
+	currentBlock := g.builder.GetInsertBlock()
+	entry := g.currentFunction.EntryBasicBlock()
+	if first := entry.FirstInstruction(); first.IsNil() {
+		g.builder.SetInsertPointAtEnd(entry)
+	} else {
+		g.builder.SetInsertPointBefore(first)
+	}
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	env := g.builder.CreateBitCast(g.currentFunction.Param(0), llvm.PointerType(bytePtr, 0), "env")
+	slot := g.builder.CreateInBoundsGEP(bytePtr, env, []llvm.Value{llvm.ConstInt(g.ctx.Int64Type(), uint64(index), false)}, "")
+	localPtr := g.builder.CreateLoad(bytePtr, slot, "")
+	captured.value = g.builder.CreateBitCast(localPtr, llvm.PointerType(g.llvmTypeFromAstType(captured.type_), 0), name.Lexeme)
+	g.builder.SetInsertPointAtEnd(currentBlock)
+
+	captured.function = g.currentFunction
+	g.closure.env.Define(name.Lexeme)
+	g.closure.env.Set(name.Lexeme, captured)
+	return captured
+}
+
+// collects the addresses of the captured variables into an env, they're all on the heap (see capturedNames)
+func (g *IRGenerator) closureEnv(captures []scanner.Token) llvm.Value {
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	malloc := g.module.NamedFunction("malloc")
+	envSize := llvm.ConstMul(llvm.SizeOf(bytePtr), llvm.ConstInt(g.ctx.Int64Type(), uint64(len(captures)), false))
+	envPtr := g.builder.CreateCall(malloc.GlobalValueType(), malloc, []llvm.Value{envSize}, "env")
+	env := g.builder.CreateBitCast(envPtr, llvm.PointerType(bytePtr, 0), "")
+	for i, name := range captures {
+		// looking the variable up again also captures it into the enclosing function when that is a closure too
+		localPtr := g.evaluateAddress(&ast.IdentifierExpr{Value: name})
+		slot := g.builder.CreateInBoundsGEP(bytePtr, env, []llvm.Value{llvm.ConstInt(g.ctx.Int64Type(), uint64(i), false)}, "")
+		g.builder.CreateStore(g.toBytePtr(localPtr), slot)
+	}
+	return envPtr
+}
+
+// calls a function value, passing its env along with the arguments
+func (g *IRGenerator) callValue(expr *ast.CallExpr, closure llvm.Value, closureType ast.Type) llvm.Value {
+	if !closureType.IsFunction || closureType.IsPointer {
+		panic(fmt.Sprintf("cannot call a value of type '%s' (line %d)", typeName(closureType), expr.Paren.Line))
+	}
+	if len(expr.Args) != len(closureType.Params) {
+		panic(fmt.Sprintf("function of type '%s' expects %d arguments but got %d (line %d)", typeName(closureType), len(closureType.Params), len(expr.Args), expr.Paren.Line))
+	}
+	args := []llvm.Value{g.builder.CreateExtractValue(closure, closureEnvIndex, "env")}
+	for i, arg := range expr.Args {
+		args = append(args, g.evaluateAs(arg, closureType.Params[i], expr.Paren.Line))
+	}
+	fnType := g.closureFnType(closureType)
+	fnPtr := g.builder.CreateBitCast(g.builder.CreateExtractValue(closure, closureFnIndex, ""), llvm.PointerType(fnType, 0), "fn")
+	name := "callRes"
+	if fnType.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		name = ""
+	}
+	g.exprTypes[expr] = *closureType.Return
+	return g.builder.CreateCall(fnType, fnPtr, args, name)
+}
+
+// named functions don't take an env, so using one as a value goes through a wrapper that does
+func (g *IRGenerator) functionValue(expr *ast.IdentifierExpr, fn variable) llvm.Value {
+	if fn.value.GlobalValueType().IsFunctionVarArg() {
+		panic(fmt.Sprintf("'%s' takes a variable number of arguments so it can only be called (line %d)", expr.Value.Lexeme, expr.Value.Line))
+	}
+	fnType := functionType(expr.Value, fn.params, fn.type_)
+	wrapperName := fn.value.Name() + ".closure"
+	wrapper := g.module.NamedFunction(wrapperName)
+	if wrapper.IsNil() {
+		wrapper = llvm.AddFunction(g.module, wrapperName, g.closureFnType(fnType))
+		wrapper.SetLinkage(llvm.InternalLinkage)
+		builder := g.ctx.NewBuilder()
+		defer builder.Dispose()
+		builder.SetInsertPointAtEnd(llvm.AddBasicBlock(wrapper, "entry"))
+		result := builder.CreateCall(fn.value.GlobalValueType(), fn.value, wrapper.Params()[1:], "")
+		if fn.value.GlobalValueType().ReturnType().TypeKind() == llvm.VoidTypeKind {
+			builder.CreateRetVoid()
+		} else {
+			builder.CreateRet(result)
+		}
+	}
+	g.exprTypes[expr] = fnType
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	return g.ctx.ConstStruct([]llvm.Value{llvm.ConstBitCast(wrapper, bytePtr), llvm.ConstPointerNull(bytePtr)}, false)
+}
@@ -0,0 +1,145 @@
+package llvm
+
+import (
+	"fmt"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"tinygo.org/x/go-llvm"
+)
+
+// error unions (T?) are lowered to { tag, payload }. a tag of 0 means the payload holds a value,
+// any other tag is an error: the index of the enum variant passed to error() plus one
+const (
+	errorTagIndex     = 0
+	errorPayloadIndex = 1
+)
+
+func (g *IRGenerator) errorUnionType(payload ast.Type) llvm.Type {
+	if payload.Token.Lexeme == "void" {
+		return g.ctx.StructType([]llvm.Type{g.ctx.Int32Type()}, false)
+	}
+	return g.ctx.StructType([]llvm.Type{g.ctx.Int32Type(), g.llvmTypeFromAstType(payload)}, false)
+}
+
+// void? has nothing besides its tag, so functions returning it only say whether they failed
+func hasPayload(unionType ast.Type) bool {
+	return unionType.Elem.Token.Lexeme != "void"
+}
+
+// the tag alone, used when a function returning void? returns without an error
+func (g *IRGenerator) errorUnionSuccess(unionType ast.Type) llvm.Value {
+	return llvm.ConstNull(g.llvmTypeFromAstType(unionType))
+}
+
+// wraps a successful value, used when returning a plain T from a function returning T?
+func (g *IRGenerator) errorUnionValue(unionType ast.Type, value llvm.Value) llvm.Value {
+	if !hasPayload(unionType) {
+		panic(fmt.Sprintf("cannot return a value from a function returning '%s' (line %d)", typeName(unionType), unionType.Token.Line))
+	}
+	payloadType := g.llvmTypeFromAstType(*unionType.Elem)
+	if value.Type() != payloadType {
+		panic(fmt.Sprintf("returned value doesn't match the function's return type (line %d)", unionType.Token.Line))
+	}
+	union := llvm.ConstNull(g.llvmTypeFromAstType(unionType))
+	return g.builder.CreateInsertValue(union, value, errorPayloadIndex, "")
+}
+
+// an error carries no payload, only its tag
+func (g *IRGenerator) errorUnionError(unionType ast.Type, tag llvm.Value) llvm.Value {
+	union := llvm.ConstNull(g.llvmTypeFromAstType(unionType))
+	return g.builder.CreateInsertValue(union, tag, errorTagIndex, "")
+}
+
+func (g *IRGenerator) VisitErrorExpr(expr *ast.ErrorExpr) llvm.Value {
+	if !g.currentReturn.IsErrorUnion {
+		panic(fmt.Sprintf("error can only be used in a function returning an error union (line %d)", expr.Keyword.Line))
+	}
+	code := g.evaluate(expr.Code)
+	codeType := g.typeOf(expr.Code)
+	if _, isEnum := g.enums[codeType.Token.Lexeme]; !isEnum || codeType.IsPointer {
+		panic(fmt.Sprintf("error code must be an enum variant (line %d)", expr.Keyword.Line))
+	}
+	tag := g.builder.CreateAdd(code, llvm.ConstInt(g.ctx.Int32Type(), 1, false), "errorTag")
+	g.exprTypes[expr] = g.currentReturn
+	return g.errorUnionError(g.currentReturn, tag)
+}
+
+func (g *IRGenerator) VisitTryExpr(expr *ast.TryExpr) llvm.Value {
+	if !g.currentReturn.IsErrorUnion {
+		panic(fmt.Sprintf("try can only be used in a function returning an error union (line %d)", expr.Keyword.Line))
+	}
+	union := g.evaluate(expr.Expression)
+	unionType := g.typeOf(expr.Expression)
+	if !unionType.IsErrorUnion {
+		panic(fmt.Sprintf("try expects a value that can be an error (line %d)", expr.Keyword.Line))
+	}
+	tag := g.builder.CreateExtractValue(union, errorTagIndex, "tag")
+	isError := g.builder.CreateICmp(llvm.IntNE, tag, llvm.ConstInt(g.ctx.Int32Type(), 0, false), "isError")
+	errorBlock := llvm.AddBasicBlock(g.currentFunction, "tryError")
+	okBlock := llvm.AddBasicBlock(g.currentFunction, "tryOk")
+	g.builder.CreateCondBr(isError, errorBlock, okBlock)
+
+	// the error is passed on to our caller as is
+	g.builder.SetInsertPointAtEnd(errorBlock)
+	g.builder.CreateRet(g.errorUnionError(g.currentReturn, tag))
+
+	g.builder.SetInsertPointAtEnd(okBlock)
+	g.exprTypes[expr] = *unionType.Elem
+	if !hasPayload(unionType) {
+		return union
+	}
+	return g.builder.CreateExtractValue(union, errorPayloadIndex, "")
+}
+
+func (g *IRGenerator) VisitCatchExpr(expr *ast.CatchExpr) llvm.Value {
+	union := g.evaluate(expr.Expression)
+	unionType := g.typeOf(expr.Expression)
+	if !unionType.IsErrorUnion {
+		panic(fmt.Sprintf("catch expects a value that can be an error (line %d)", expr.Keyword.Line))
+	}
+	if !hasPayload(unionType) {
+		return g.catchVoid(expr, union, unionType)
+	}
+	payload := g.builder.CreateExtractValue(union, errorPayloadIndex, "")
+	tag := g.builder.CreateExtractValue(union, errorTagIndex, "tag")
+	isError := g.builder.CreateICmp(llvm.IntNE, tag, llvm.ConstInt(g.ctx.Int32Type(), 0, false), "isError")
+	okBlock := g.builder.GetInsertBlock()
+	fallbackBlock := llvm.AddBasicBlock(g.currentFunction, "catchFallback")
+	mergeBlock := llvm.AddBasicBlock(g.currentFunction, "catchMerge")
+	g.builder.CreateCondBr(isError, fallbackBlock, mergeBlock)
+
+	// the fallback is only evaluated when there was an error
+	g.builder.SetInsertPointAtEnd(fallbackBlock)
+	fallback := g.coerce(expr.Fallback, g.evaluate(expr.Fallback), *unionType.Elem, expr.Keyword.Line)
+	if fallback.Type() != payload.Type() {
+		panic(fmt.Sprintf("catch fallback doesn't match the type of the value (line %d)", expr.Keyword.Line))
+	}
+	fallbackBlock = g.builder.GetInsertBlock()
+	g.builder.CreateBr(mergeBlock)
+
+	g.builder.SetInsertPointAtEnd(mergeBlock)
+	phi := g.builder.CreatePHI(payload.Type(), "")
+	phi.AddIncoming([]llvm.Value{payload, fallback}, []llvm.BasicBlock{okBlock, fallbackBlock})
+	g.exprTypes[expr] = *unionType.Elem
+	return phi
+}
+
+// with nothing to fall back to, catch on void? only runs its fallback when there was an error, like save() catch report()
+func (g *IRGenerator) catchVoid(expr *ast.CatchExpr, union llvm.Value, unionType ast.Type) llvm.Value {
+	tag := g.builder.CreateExtractValue(union, errorTagIndex, "tag")
+	isError := g.builder.CreateICmp(llvm.IntNE, tag, llvm.ConstInt(g.ctx.Int32Type(), 0, false), "isError")
+	fallbackBlock := llvm.AddBasicBlock(g.currentFunction, "catchFallback")
+	mergeBlock := llvm.AddBasicBlock(g.currentFunction, "catchMerge")
+	g.builder.CreateCondBr(isError, fallbackBlock, mergeBlock)
+
+	g.builder.SetInsertPointAtEnd(fallbackBlock)
+	g.evaluate(expr.Fallback)
+	if fallbackType := g.typeOf(expr.Fallback); fallbackType.Token.Lexeme != "void" || fallbackType.IsPointer {
+		panic(fmt.Sprintf("catch on '%s' has no value to fall back to, its fallback must return nothing like a call to a void function (line %d)", typeName(unionType), expr.Keyword.Line))
+	}
+	g.builder.CreateBr(mergeBlock)
+
+	g.builder.SetInsertPointAtEnd(mergeBlock)
+	g.exprTypes[expr] = *unionType.Elem
+	return union
+}
@@ -0,0 +1,456 @@
+package llvm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// generic functions and structs are only generated once they're used with concrete types. each distinct set of
+// type arguments gets its own copy in the module, named after them like max[i32] or Pair[number]
+
+// a generic function specialized for one set of type arguments
+type instanceInfo struct {
+	fn   llvm.Value
+	stmt *ast.FnStmt // with the type params in its signature already replaced
+}
+
+// the body of an instance, generated after the top level statements so every function is declared by then
+type pendingBody struct {
+	fn         llvm.Value
+	stmt       *ast.FnStmt
+	generic    *ast.FnStmt
+	receiver   *ast.Type
+	typeArgs   map[string]ast.Type
+	typeParams []ast.TypeParam
+	structName string
+}
+
+// bounds every generic can use without declaring them. operators can only be used on values of a type param
+// bounded by one of these, since an unbounded one could just as well be a struct
+var builtinBounds = map[string]struct {
+	operators []scanner.TokenType
+	allows    func(g *IRGenerator, type_ ast.Type) bool
+}{
+	"Comparable": {
+		operators: []scanner.TokenType{scanner.EQUAL, scanner.NOT_EQUAL},
+		allows: func(g *IRGenerator, type_ ast.Type) bool {
+			_, isEnum := g.enums[type_.Token.Lexeme]
+			return isNumeric(type_) || isString(type_) || isEnum || type_.IsPointer || isPrimitive(type_, "bool") || isPrimitive(type_, "char")
+		},
+	},
+	"Ordered": {
+		operators: []scanner.TokenType{scanner.EQUAL, scanner.NOT_EQUAL, scanner.LESS, scanner.LESS_EQ, scanner.GREATER, scanner.GREATER_EQ},
+		allows: func(g *IRGenerator, type_ ast.Type) bool {
+			return isNumeric(type_) || isPrimitive(type_, "char")
+		},
+	},
+	"Numeric": {
+		operators: []scanner.TokenType{scanner.EQUAL, scanner.NOT_EQUAL, scanner.LESS, scanner.LESS_EQ, scanner.GREATER, scanner.GREATER_EQ,
+			scanner.PLUS, scanner.MINUS, scanner.STAR, scanner.SLASH, scanner.MOD, scanner.ADDRESS, scanner.PIPE, scanner.CARET,
+			scanner.TILDE, scanner.SHIFT_LEFT, scanner.SHIFT_RIGHT, scanner.PLUSPLUS, scanner.MINUSMINUS},
+		allows: func(g *IRGenerator, type_ ast.Type) bool {
+			return isNumeric(type_)
+		},
+	},
+}
+
+func mangleGenericName(name string, typeArgs []ast.Type) string {
+	names := make([]string, 0)
+	for _, typeArg := range typeArgs {
+		names = append(names, typeName(typeArg))
+	}
+	return name + "[" + strings.Join(names, ", ") + "]"
+}
+
+func bindTypeArgs(typeParams []ast.TypeParam, typeArgs []ast.Type) map[string]ast.Type {
+	bindings := make(map[string]ast.Type)
+	for i, typeParam := range typeParams {
+		bindings[typeParam.Name.Lexeme] = typeArgs[i]
+	}
+	return bindings
+}
+
+// drops the marks left by the body a type was inferred in, instances are shared by every caller
+func withoutTypeParams(type_ ast.Type) ast.Type {
+	type_.TypeParam = nil
+	if type_.Elem != nil {
+		elem := withoutTypeParams(*type_.Elem)
+		type_.Elem = &elem
+	}
+	if type_.Return != nil {
+		returnType := withoutTypeParams(*type_.Return)
+		type_.Return = &returnType
+	}
+	if type_.IsFunction {
+		params := make([]ast.Type, 0)
+		for _, param := range type_.Params {
+			params = append(params, withoutTypeParams(param))
+		}
+		type_.Params = params
+	}
+	if type_.IsTuple {
+		elems := make([]ast.Type, 0)
+		for _, elem := range type_.Elems {
+			elems = append(elems, withoutTypeParams(elem))
+		}
+		type_.Elems = elems
+	}
+	return type_
+}
+
+func isTypeParam(typeParams []ast.TypeParam, type_ ast.Type) bool {
+	if type_.IsArray || type_.IsSlice || type_.IsErrorUnion || type_.IsFunction || type_.IsTuple || len(type_.TypeArgs) > 0 {
+		return false
+	}
+	for _, typeParam := range typeParams {
+		if typeParam.Name.Lexeme == type_.Token.Lexeme {
+			return true
+		}
+	}
+	return false
+}
+
+// replaces the type params of the generic being generated with their arguments, and generic structs given
+// type arguments with their instance for them
+func (g *IRGenerator) resolveType(type_ ast.Type) ast.Type {
+	if type_.Elem != nil {
+		elem := g.resolveType(*type_.Elem)
+		type_.Elem = &elem
+	}
+	if type_.IsFunction {
+		params := make([]ast.Type, 0)
+		for _, param := range type_.Params {
+			params = append(params, g.resolveType(param))
+		}
+		returnType := g.resolveType(*type_.Return)
+		type_.Params, type_.Return = params, &returnType
+		return type_
+	}
+	if type_.IsTuple {
+		elems := make([]ast.Type, 0)
+		for _, elem := range type_.Elems {
+			elems = append(elems, g.resolveType(elem))
+		}
+		type_.Elems = elems
+		return type_
+	}
+	if type_.IsArray || type_.IsSlice || type_.IsErrorUnion {
+		return type_
+	}
+	if typeArg, isBound := g.typeArgs[type_.Token.Lexeme]; isBound {
+		if len(type_.TypeArgs) > 0 {
+			panic(fmt.Sprintf("type parameter '%s' can't take type arguments (line %d)", type_.Token.Lexeme, type_.Token.Line))
+		}
+		if typeArg.IsPointer && type_.IsPointer {
+			panic(fmt.Sprintf("cannot point to '%s' since '%s' is already the pointer '%s' (line %d)", type_.Token.Lexeme, type_.Token.Lexeme, typeName(typeArg), type_.Token.Line))
+		}
+		typeArg.IsPointer = typeArg.IsPointer || type_.IsPointer
+		typeArg.IsNullable = typeArg.IsNullable || type_.IsNullable
+		// inside the body, values of the type param are told apart from ones that happen to have the same type
+		for i := range g.typeParams {
+			if g.typeParams[i].Name.Lexeme == type_.Token.Lexeme {
+				typeArg.TypeParam = &g.typeParams[i]
+			}
+		}
+		return typeArg
+	}
+	generic, isGeneric := g.genericStructs[type_.Token.Lexeme]
+	if !isGeneric {
+		if len(type_.TypeArgs) > 0 {
+			panic(fmt.Sprintf("'%s' is not generic so it can't take type arguments (line %d)", type_.Token.Lexeme, type_.Token.Line))
+		}
+		return type_
+	}
+	if len(type_.TypeArgs) != len(generic.TypeParams) {
+		panic(fmt.Sprintf("struct '%s' expects %d type arguments but got %d (line %d)", generic.Name.Lexeme, len(generic.TypeParams), len(type_.TypeArgs), type_.Token.Line))
+	}
+	typeArgs := make([]ast.Type, 0)
+	for _, typeArg := range type_.TypeArgs {
+		typeArgs = append(typeArgs, g.resolveType(typeArg))
+	}
+	type_.Token.Type = scanner.TYPE
+	type_.Token.Lexeme = g.instantiateStruct(generic, typeArgs, type_.Token.Line)
+	type_.TypeArgs = nil
+	return type_
+}
+
+func (g *IRGenerator) resolveParams(params []ast.Param) []ast.Param {
+	resolved := make([]ast.Param, 0)
+	for _, param := range params {
+		param.Type = g.resolveType(param.Type)
+		resolved = append(resolved, param)
+	}
+	return resolved
+}
+
+// a copy of the function whose signature uses resolved types, the body is shared
+func (g *IRGenerator) resolveFn(stmt *ast.FnStmt) *ast.FnStmt {
+	resolved := *stmt
+	resolved.TypeParams = nil
+	resolved.Params = g.resolveParams(stmt.Params)
+	resolved.Return = g.resolveType(stmt.Return)
+	return &resolved
+}
+
+// works out each type param from the types of the values given for the params declared with them.
+// number literals and nil only decide a type param nothing else did, so max(x, 1) takes the type of x.
+// hints come from where the result is stored and go in between, so let y i32 = max(1, 2) is a max[i32]
+func (g *IRGenerator) inferTypeArgs(name scanner.Token, typeParams []ast.TypeParam, params []ast.Type, args []ast.Expr, hints map[string]ast.Type) []ast.Type {
+	bindings := make(map[string]ast.Type)
+	for i, arg := range args {
+		argType := g.typeOf(arg)
+		if _, isLiteral := literalValue(arg); !isLiteral && !isNil(argType) {
+			g.unify(name, typeParams, bindings, params[i], argType)
+		}
+	}
+	for typeParam, hint := range hints {
+		if _, isBound := bindings[typeParam]; !isBound {
+			bindings[typeParam] = hint
+		}
+	}
+	for i, arg := range args {
+		_, isLiteral := literalValue(arg)
+		if _, isBound := bindings[params[i].Token.Lexeme]; isLiteral && !isBound && isTypeParam(typeParams, params[i]) {
+			bindings[params[i].Token.Lexeme] = g.typeOf(arg)
+		}
+	}
+	typeArgs := make([]ast.Type, 0)
+	for _, typeParam := range typeParams {
+		typeArg, isBound := bindings[typeParam.Name.Lexeme]
+		if !isBound {
+			panic(fmt.Sprintf("cannot infer type parameter '%s' of '%s' from its arguments (line %d)", typeParam.Name.Lexeme, name.Lexeme, name.Line))
+		}
+		typeArgs = append(typeArgs, typeArg)
+	}
+	return typeArgs
+}
+
+// matches the declared type of a param against the type of its argument, binding the type params it mentions.
+// shapes that don't match bind nothing and are reported by coerce once the instance is known
+func (g *IRGenerator) unify(name scanner.Token, typeParams []ast.TypeParam, bindings map[string]ast.Type, param ast.Type, arg ast.Type) {
+	switch {
+	case isTypeParam(typeParams, param):
+		if param.IsPointer {
+			if !arg.IsPointer {
+				return
+			}
+			arg.IsPointer, arg.IsNullable = false, false
+		}
+		if bound, isBound := bindings[param.Token.Lexeme]; isBound {
+			if typeName(bound) != typeName(arg) {
+				panic(fmt.Sprintf("type parameter '%s' of '%s' can't be both '%s' and '%s' (line %d)", param.Token.Lexeme, name.Lexeme, typeName(bound), typeName(arg), name.Line))
+			}
+			return
+		}
+		bindings[param.Token.Lexeme] = arg
+	case param.IsFunction && arg.IsFunction && len(param.Params) == len(arg.Params):
+		for i := range param.Params {
+			g.unify(name, typeParams, bindings, param.Params[i], arg.Params[i])
+		}
+		g.unify(name, typeParams, bindings, *param.Return, *arg.Return)
+	case param.IsTuple && arg.IsTuple && len(param.Elems) == len(arg.Elems):
+		for i := range param.Elems {
+			g.unify(name, typeParams, bindings, param.Elems[i], arg.Elems[i])
+		}
+	case param.Elem != nil && arg.Elem != nil && param.IsArray == arg.IsArray && param.IsSlice == arg.IsSlice:
+		g.unify(name, typeParams, bindings, *param.Elem, *arg.Elem)
+	case len(param.TypeArgs) > 0:
+		// an instance remembers the generic and the type arguments it came from
+		if instance, exists := g.structs[arg.Token.Lexeme]; exists && instance.generic == param.Token.Lexeme {
+			for i := range param.TypeArgs {
+				g.unify(name, typeParams, bindings, param.TypeArgs[i], instance.typeArgs[i])
+			}
+		}
+	}
+}
+
+// a call to a generic function instantiates it for the types of its arguments. target is the type the result
+// is stored as when there is one, see inferTypeArgs
+func (g *IRGenerator) genericCall(expr *ast.CallExpr, name scanner.Token, generic *ast.FnStmt, target *ast.Type) llvm.Value {
+	if len(expr.Args) != len(generic.Params) {
+		panic(fmt.Sprintf("function '%s' expects %d arguments but got %d (line %d)", name.Lexeme, len(generic.Params), len(expr.Args), expr.Paren.Line))
+	}
+	args := make([]llvm.Value, 0)
+	paramTypes := make([]ast.Type, 0)
+	for i, arg := range expr.Args {
+		args = append(args, g.evaluate(arg))
+		paramTypes = append(paramTypes, generic.Params[i].Type)
+	}
+	var typeArgs []ast.Type
+	if len(expr.TypeArgs) > 0 {
+		if len(expr.TypeArgs) != len(generic.TypeParams) {
+			panic(fmt.Sprintf("function '%s' expects %d type arguments but got %d (line %d)", name.Lexeme, len(generic.TypeParams), len(expr.TypeArgs), expr.Paren.Line))
+		}
+		for _, typeArg := range expr.TypeArgs {
+			typeArgs = append(typeArgs, g.resolveType(typeArg))
+		}
+	} else {
+		hints := make(map[string]ast.Type)
+		if target != nil {
+			g.unify(name, generic.TypeParams, hints, generic.Return, *target)
+		}
+		typeArgs = g.inferTypeArgs(name, generic.TypeParams, paramTypes, expr.Args, hints)
+	}
+	instance := g.instantiateFn(generic, typeArgs, expr.Paren.Line)
+	for i, arg := range expr.Args {
+		args[i] = g.coerce(arg, args[i], instance.stmt.Params[i].Type, expr.Paren.Line)
+	}
+	g.exprTypes[expr] = instance.stmt.Return
+	return g.createCall(instance.fn, args)
+}
+
+// id[Point](p) parses as indexing id since Point could be a variable, it's turned into the type argument here
+func (g *IRGenerator) typeArgsFromIndex(expr *ast.CallExpr) {
+	index, ok := expr.Callee.(*ast.IndexExpr)
+	if !ok {
+		return
+	}
+	object, isIdentifier := index.Object.(*ast.IdentifierExpr)
+	typeArg, isName := index.Index.(*ast.IdentifierExpr)
+	if !isIdentifier || !isName {
+		return
+	}
+	callee, exists := g.environment.Get(object.Value.Lexeme)
+	if _, isVariable := g.environment.Get(typeArg.Value.Lexeme); !exists || callee.generic == nil || isVariable {
+		return
+	}
+	token := typeArg.Value
+	token.Type = scanner.TYPE
+	expr.Callee, expr.TypeArgs = object, []ast.Type{{Token: token}}
+}
+
+func (g *IRGenerator) instantiateFn(generic *ast.FnStmt, typeArgs []ast.Type, line int) *instanceInfo {
+	name := mangleGenericName(generic.Name.Lexeme, typeArgs)
+	if instance, exists := g.fnInstances[name]; exists {
+		return instance
+	}
+	for i := range typeArgs {
+		typeArgs[i] = withoutTypeParams(typeArgs[i])
+	}
+	g.checkBounds(generic.Name, generic.TypeParams, typeArgs, line)
+	prevTypeArgs, prevTypeParams := g.typeArgs, g.typeParams
+	g.typeArgs, g.typeParams = bindTypeArgs(generic.TypeParams, typeArgs), nil
+	stmt := g.resolveFn(generic)
+	instance := &instanceInfo{fn: g.declareFunction(name, stmt, nil), stmt: stmt}
+	g.fnInstances[name] = instance
+	g.pendingBodies = append(g.pendingBodies, pendingBody{fn: instance.fn, stmt: stmt, generic: generic, typeArgs: g.typeArgs, typeParams: generic.TypeParams})
+	g.typeArgs, g.typeParams = prevTypeArgs, prevTypeParams
+	return instance
+}
+
+// creates the struct type and method prototypes of a generic struct for the given type arguments
+func (g *IRGenerator) instantiateStruct(generic *ast.StructStmt, typeArgs []ast.Type, line int) string {
+	name := mangleGenericName(generic.Name.Lexeme, typeArgs)
+	if _, exists := g.structs[name]; exists {
+		return name
+	}
+	for i := range typeArgs {
+		typeArgs[i] = withoutTypeParams(typeArgs[i])
+	}
+	g.checkBounds(generic.Name, generic.TypeParams, typeArgs, line)
+	prevTypeArgs, prevTypeParams := g.typeArgs, g.typeParams
+	g.typeArgs, g.typeParams = bindTypeArgs(generic.TypeParams, typeArgs), nil
+	// registered before the fields are resolved so they can point back to the instance
+	info := &structInfo{
+		llvmType: g.ctx.StructCreateNamed(name),
+		methods:  make(map[string]*methodInfo),
+		generic:  generic.Name.Lexeme,
+		typeArgs: typeArgs,
+	}
+	g.structs[name] = info
+	fieldTypes := make([]llvm.Type, 0)
+	for _, field := range generic.Fields {
+		field.Type = g.resolveType(field.Type)
+		info.fields = append(info.fields, field)
+		fieldTypes = append(fieldTypes, g.llvmTypeFromAstType(field.Type))
+	}
+	info.llvmType.StructSetBody(fieldTypes, false)
+
+	receiver := ast.Type{Token: scanner.Token{Type: scanner.TYPE, Lexeme: name, Line: generic.Name.Line}, IsPointer: true}
+	for _, method := range generic.Methods {
+		stmt := g.resolveFn(method.Fn)
+		fn := g.declareFunction(mangleMethodName(name, method.Fn.Name.Lexeme), stmt, &receiver)
+		info.methods[method.Fn.Name.Lexeme] = &methodInfo{fn: fn, stmt: stmt, isPublic: method.IsPublic}
+		g.pendingBodies = append(g.pendingBodies, pendingBody{fn: fn, stmt: stmt, generic: method.Fn, receiver: &receiver, typeArgs: g.typeArgs, typeParams: generic.TypeParams, structName: name})
+	}
+	g.typeArgs, g.typeParams = prevTypeArgs, prevTypeParams
+	for _, iface := range generic.Implements {
+		g.checkImplements(receiver, iface, line)
+	}
+	return name
+}
+
+// generic struct literals infer their type arguments from the field values, Pair{first: 1, second: 2} is a Pair[number]
+func (g *IRGenerator) genericStructLiteral(expr *ast.StructLiteralExpr, generic *ast.StructStmt) (string, []llvm.Value) {
+	values := make([]llvm.Value, 0)
+	fieldTypes := make([]ast.Type, 0)
+	for i, field := range expr.Fields {
+		index := -1
+		for j, declared := range generic.Fields {
+			if declared.Name.Lexeme == field.Lexeme {
+				index = j
+			}
+		}
+		if index < 0 {
+			panic(fmt.Sprintf("struct '%s' has no field '%s' (line %d)", expr.Name.Lexeme, field.Lexeme, field.Line))
+		}
+		values = append(values, g.evaluate(expr.Values[i]))
+		fieldTypes = append(fieldTypes, generic.Fields[index].Type)
+	}
+	return g.instantiateStruct(generic, g.inferTypeArgs(expr.Name, generic.TypeParams, fieldTypes, expr.Values, nil), expr.Name.Line), values
+}
+
+// type arguments have to implement the interfaces their type param is bounded by, or be one of the types a
+// builtin bound allows
+func (g *IRGenerator) checkBounds(name scanner.Token, typeParams []ast.TypeParam, typeArgs []ast.Type, line int) {
+	for i, typeParam := range typeParams {
+		for _, bound := range typeParam.Bounds {
+			if builtin, isBuiltin := builtinBounds[bound.Lexeme]; isBuiltin {
+				if !builtin.allows(g, typeArgs[i]) {
+					panic(fmt.Sprintf("'%s' can't be used for type parameter '%s' of '%s' since it isn't '%s' (line %d)", typeName(typeArgs[i]), typeParam.Name.Lexeme, name.Lexeme, bound.Lexeme, line))
+				}
+				continue
+			}
+			g.checkImplements(typeArgs[i], bound, line)
+		}
+	}
+}
+
+// operators on a value of a type param need a bound that allows them, whatever the type argument turned out to be
+func checkTypeParamOperator(operator scanner.Token, type_ ast.Type) {
+	if type_.TypeParam == nil || type_.IsPointer {
+		return
+	}
+	for _, bound := range type_.TypeParam.Bounds {
+		if builtin, isBuiltin := builtinBounds[bound.Lexeme]; isBuiltin && slices.Contains(builtin.operators, operator.Type) {
+			return
+		}
+	}
+	name := type_.TypeParam.Name.Lexeme
+	for _, suggestion := range []string{"Comparable", "Ordered", "Numeric"} {
+		if slices.Contains(builtinBounds[suggestion].operators, operator.Type) {
+			panic(fmt.Sprintf("operator '%s' can't be used on type parameter '%s' unless it's bounded like '%s: %s' (line %d)", operator.Lexeme, name, name, suggestion, operator.Line))
+		}
+	}
+	panic(fmt.Sprintf("operator '%s' can't be used on type parameter '%s' (line %d)", operator.Lexeme, name, operator.Line))
+}
+
+// instance bodies can instantiate more generics themselves, so this runs until none are left
+func (g *IRGenerator) generatePendingBodies() {
+	for len(g.pendingBodies) > 0 {
+		body := g.pendingBodies[0]
+		g.pendingBodies = g.pendingBodies[1:]
+		g.typeArgs, g.typeParams, g.currentStruct = body.typeArgs, body.typeParams, body.structName
+		// resolved again now the type params are marked, for the params declared in the body
+		stmt := *body.stmt
+		stmt.Params = g.resolveParams(body.generic.Params)
+		stmt.Return = g.resolveType(body.generic.Return)
+		g.defineFunctionBody(body.fn, &stmt, body.receiver)
+	}
+	g.typeArgs, g.typeParams, g.currentStruct = nil, nil, ""
+}
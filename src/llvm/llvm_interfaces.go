@@ -0,0 +1,177 @@
+package llvm
+
+import (
+	"fmt"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// values of an interface type are lowered to { data, vtable }. data points to the struct the value was made from
+// and vtable to a constant table holding that struct's methods in the order the interface declares them
+const (
+	interfaceDataIndex   = 0
+	interfaceVtableIndex = 1
+)
+
+// the methods a struct needs to implement an interface. structs never say so unless they want it checked
+// where they're declared, any struct with matching pub methods can be used as the interface
+type interfaceInfo struct {
+	methods []*ast.FnStmt
+}
+
+func (i *interfaceInfo) methodIndex(name string) (int, bool) {
+	for index, method := range i.methods {
+		if method.Name.Lexeme == name {
+			return index, true
+		}
+	}
+	return 0, false
+}
+
+func (g *IRGenerator) interfaceType() llvm.Type {
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	return g.ctx.StructType([]llvm.Type{bytePtr, bytePtr}, false)
+}
+
+func (g *IRGenerator) isInterface(type_ ast.Type) bool {
+	_, exists := g.interfaces[type_.Token.Lexeme]
+	return exists && !type_.IsArray && !type_.IsSlice && !type_.IsErrorUnion && !type_.IsFunction
+}
+
+func (g *IRGenerator) declareInterfaces(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		if interfaceStmt, ok := stmt.(*ast.InterfaceStmt); ok {
+			if _, isBuiltin := builtinBounds[interfaceStmt.Name.Lexeme]; isBuiltin {
+				panic(fmt.Sprintf("interface '%s' has the name of a builtin bound (line %d)", interfaceStmt.Name.Lexeme, interfaceStmt.Name.Line))
+			}
+			if _, exists := g.interfaces[interfaceStmt.Name.Lexeme]; exists {
+				panic(fmt.Sprintf("interface '%s' declared more than once (line %d)", interfaceStmt.Name.Lexeme, interfaceStmt.Name.Line))
+			}
+			g.interfaces[interfaceStmt.Name.Lexeme] = &interfaceInfo{methods: interfaceStmt.Methods}
+		}
+	}
+}
+
+func (g *IRGenerator) VisitInterfaceStmt(stmt *ast.InterfaceStmt) {
+	// interfaces only exist at compile time, see declareInterfaces
+	if g.depth != 0 {
+		panic(fmt.Sprintf("interface '%s' must be declared at the top level (line %d)", stmt.Name.Lexeme, stmt.Name.Line))
+	}
+}
+
+// the signature of an interface method with its types resolved. interfaces aren't generic, so type params
+// of whatever is being generated don't apply to them
+func (g *IRGenerator) interfaceMethod(iface *interfaceInfo, index int) *ast.FnStmt {
+	prevTypeArgs := g.typeArgs
+	g.typeArgs = nil
+	method := g.resolveFn(iface.methods[index])
+	g.typeArgs = prevTypeArgs
+	return method
+}
+
+// panics unless values of the type can be used as the interface, either being a struct with every one of its
+// methods or the interface itself
+func (g *IRGenerator) checkImplements(type_ ast.Type, ifaceName scanner.Token, line int) {
+	iface, exists := g.interfaces[ifaceName.Lexeme]
+	if !exists {
+		panic(fmt.Sprintf("unknown interface '%s' (line %d)", ifaceName.Lexeme, line))
+	}
+	if g.isInterface(type_) && type_.Token.Lexeme == ifaceName.Lexeme && !type_.IsPointer {
+		return
+	}
+	info, isStruct := g.structs[type_.Token.Lexeme]
+	if !g.isStruct(type_) || !isStruct {
+		panic(fmt.Sprintf("'%s' doesn't implement interface '%s' since only structs can (line %d)", typeName(type_), ifaceName.Lexeme, line))
+	}
+	for i, required := range iface.methods {
+		method, exists := info.methods[required.Name.Lexeme]
+		if !exists {
+			panic(fmt.Sprintf("struct '%s' doesn't implement interface '%s', it has no method '%s' (line %d)", type_.Token.Lexeme, ifaceName.Lexeme, required.Name.Lexeme, line))
+		}
+		if !method.isPublic {
+			panic(fmt.Sprintf("struct '%s' doesn't implement interface '%s', method '%s' isn't pub (line %d)", type_.Token.Lexeme, ifaceName.Lexeme, required.Name.Lexeme, line))
+		}
+		required = g.interfaceMethod(iface, i)
+		want := typeName(functionType(required.Name, required.Params, required.Return))
+		got := typeName(functionType(method.stmt.Name, method.stmt.Params, method.stmt.Return))
+		if want != got {
+			panic(fmt.Sprintf("struct '%s' doesn't implement interface '%s', method '%s' is '%s' instead of '%s' (line %d)", type_.Token.Lexeme, ifaceName.Lexeme, required.Name.Lexeme, got, want, line))
+		}
+	}
+}
+
+// the constant table of a struct's methods that interface values made from it call through
+func (g *IRGenerator) vtable(structName string, ifaceName string) llvm.Value {
+	name := structName + "." + ifaceName + ".vtable"
+	if vtable := g.module.NamedGlobal(name); !vtable.IsNil() {
+		return vtable
+	}
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	entries := make([]llvm.Value, 0)
+	for _, method := range g.interfaces[ifaceName].methods {
+		entries = append(entries, llvm.ConstBitCast(g.structs[structName].methods[method.Name.Lexeme].fn, bytePtr))
+	}
+	table := llvm.ConstArray(bytePtr, entries)
+	vtable := llvm.AddGlobal(g.module, table.Type(), name)
+	vtable.SetInitializer(table)
+	vtable.SetGlobalConstant(true)
+	vtable.SetLinkage(llvm.PrivateLinkage)
+	vtable.SetUnnamedAddr(true)
+	return vtable
+}
+
+// turns a struct or a pointer to one into a value of the interface type. structs are copied to the heap so the
+// interface value can outlive them, pointers are kept so methods called through the interface see the original
+func (g *IRGenerator) interfaceValue(value llvm.Value, valueType ast.Type, target ast.Type, line int) llvm.Value {
+	if g.isInterface(valueType) && !valueType.IsPointer {
+		if valueType.Token.Lexeme != target.Token.Lexeme {
+			panic(fmt.Sprintf("cannot use a value of type '%s' as '%s' (line %d)", typeName(valueType), typeName(target), line))
+		}
+		return value
+	}
+	g.checkImplements(valueType, target.Token, line)
+	data := value
+	if !valueType.IsPointer {
+		malloc := g.module.NamedFunction("malloc")
+		data = g.builder.CreateCall(malloc.GlobalValueType(), malloc, []llvm.Value{llvm.SizeOf(value.Type())}, "")
+		g.builder.CreateStore(value, g.builder.CreateBitCast(data, llvm.PointerType(value.Type(), 0), ""))
+	}
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	vtable := llvm.ConstBitCast(g.vtable(valueType.Token.Lexeme, target.Token.Lexeme), bytePtr)
+	ifaceVal := g.builder.CreateInsertValue(llvm.Undef(g.interfaceType()), g.builder.CreateBitCast(data, bytePtr, ""), interfaceDataIndex, "")
+	return g.builder.CreateInsertValue(ifaceVal, vtable, interfaceVtableIndex, target.Token.Lexeme)
+}
+
+// calls a method of whatever struct is behind an interface value by looking it up in the vtable
+func (g *IRGenerator) interfaceCall(expr *ast.CallExpr, callee *ast.GetExpr, ifaceVal llvm.Value, ifaceType ast.Type) llvm.Value {
+	iface := g.interfaces[ifaceType.Token.Lexeme]
+	index, exists := iface.methodIndex(callee.Name.Lexeme)
+	if !exists {
+		panic(fmt.Sprintf("interface '%s' has no method '%s' (line %d)", ifaceType.Token.Lexeme, callee.Name.Lexeme, callee.Name.Line))
+	}
+	method := g.interfaceMethod(iface, index)
+	if len(expr.Args) != len(method.Params) {
+		panic(fmt.Sprintf("method '%s' expects %d arguments but got %d (line %d)", callee.Name.Lexeme, len(method.Params), len(expr.Args), expr.Paren.Line))
+	}
+
+	// methods take a pointer to their struct first, which is what data is
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	args := []llvm.Value{g.builder.CreateExtractValue(ifaceVal, interfaceDataIndex, "data")}
+	paramTypes := []llvm.Type{bytePtr}
+	for i, arg := range expr.Args {
+		args = append(args, g.evaluateAs(arg, method.Params[i].Type, expr.Paren.Line))
+		paramTypes = append(paramTypes, g.llvmTypeFromAstType(method.Params[i].Type))
+	}
+	fnType := llvm.FunctionType(g.llvmTypeFromAstType(method.Return), paramTypes, false)
+	vtable := g.builder.CreateBitCast(g.builder.CreateExtractValue(ifaceVal, interfaceVtableIndex, ""), llvm.PointerType(bytePtr, 0), "vtable")
+	slot := g.builder.CreateInBoundsGEP(bytePtr, vtable, []llvm.Value{llvm.ConstInt(g.ctx.Int64Type(), uint64(index), false)}, "")
+	fnPtr := g.builder.CreateBitCast(g.builder.CreateLoad(bytePtr, slot, ""), llvm.PointerType(fnType, 0), callee.Name.Lexeme)
+	name := "callRes"
+	if fnType.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		name = ""
+	}
+	g.exprTypes[expr] = method.Return
+	return g.builder.CreateCall(fnType, fnPtr, args, name)
+}
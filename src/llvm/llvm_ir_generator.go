@@ -2,7 +2,10 @@ package llvm
 
 import (
 	"fmt"
+	"go/constant"
+	"go/token"
 	"os"
+	"strings"
 
 	"github.com/prometheus1400/kel/src/ast"
 	"github.com/prometheus1400/kel/src/environment"
@@ -11,19 +14,111 @@ import (
 )
 
 type IRGenerator struct {
+	// traps when an integer is divided by zero instead of leaving it undefined
+	CheckDivision bool
+
 	ctx               llvm.Context
 	module            llvm.Module
 	builder           llvm.Builder
 	depth             int
-	environment       *environment.Environment[llvm.Value]
+	environment       *environment.Environment[variable]
 	identifierAddress bool
 	currentFunction   llvm.Value
+	currentReturn     ast.Type
+	loops             []loopBlocks
+	exprTypes         map[ast.Expr]ast.Type
+	structs           map[string]*structInfo
+	currentStruct     string
+	enums             map[string]*enumInfo
+	stringLiterals    map[string]llvm.Value
+	closure           *closureInfo
+	captured          map[string]bool // names the anonymous functions in the current function use, see capturedNames
+	genericStructs    map[string]*ast.StructStmt
+	fnInstances       map[string]*instanceInfo
+	typeArgs          map[string]ast.Type // what the type params of the generic being generated stand for
+	typeParams        []ast.TypeParam     // the type params of the instance body being generated, see resolveType
+	pendingBodies     []pendingBody
+	interfaces        map[string]*interfaceInfo
+}
+
+// enums lower to plain integers, each variant being its index in the declaration
+type enumInfo struct {
+	variants []scanner.Token
+}
+
+func (e *enumInfo) variantIndex(name string) (int, bool) {
+	for i, variant := range e.variants {
+		if variant.Lexeme == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// a user defined struct and the llvm named type it lowers to
+type structInfo struct {
+	llvmType llvm.Type
+	fields   []ast.Field
+	methods  map[string]*methodInfo
+	// for instances of a generic struct, the struct they came from and their type arguments
+	generic  string
+	typeArgs []ast.Type
+}
+
+type methodInfo struct {
+	fn       llvm.Value
+	stmt     *ast.FnStmt
+	isPublic bool
+	modifies *bool // whether it can change its receiver, worked out the first time it's called
+}
+
+func (s *structInfo) fieldIndex(name string) (int, bool) {
+	for i, field := range s.fields {
+		if field.Name.Lexeme == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// what an identifier resolves to along with the language type it was declared with
+type variable struct {
+	value   llvm.Value  // alloca or global holding the value, or the function itself
+	type_   ast.Type    // for functions this is the return type
+	builtin bool        // builtins like len and append are generated inline at each call
+	isConst bool        // declared with const so it can never be assigned to
+	params  []ast.Param // for functions, the declared params that arguments are checked against
+	generic *ast.FnStmt // generic functions are only generated for the type arguments they are called with
+	// the function a local variable belongs to, unset for globals and functions
+	function llvm.Value
+	boxed    bool // lives on the heap so the closures capturing it share it with the function
+	// a nullable pointer that a nil check showed to be non-nil, and how many loops surrounded that check
+	narrowed      bool
+	narrowedLoops int
+}
+
+// jump targets for break and continue inside of the innermost loop
+type loopBlocks struct {
+	continueBlock llvm.BasicBlock
+	breakBlock    llvm.BasicBlock
 }
 
 func (g *IRGenerator) Init() {
 	g.depth = 0
-	g.environment = environment.NewEnvironment[llvm.Value](nil)
+	g.environment = environment.NewEnvironment[variable](nil)
 	g.identifierAddress = false
+	g.loops = nil
+	g.exprTypes = make(map[ast.Expr]ast.Type)
+	g.structs = make(map[string]*structInfo)
+	g.currentStruct = ""
+	g.enums = make(map[string]*enumInfo)
+	g.stringLiterals = make(map[string]llvm.Value)
+	g.closure = nil
+	g.genericStructs = make(map[string]*ast.StructStmt)
+	g.fnInstances = make(map[string]*instanceInfo)
+	g.typeArgs = nil
+	g.pendingBodies = nil
+	g.interfaces = make(map[string]*interfaceInfo)
 
 	llvm.InitializeAllTargetInfos()
 	llvm.InitializeAllTargets()
@@ -48,11 +143,15 @@ func (g *IRGenerator) GenerateIR(stmts []ast.Stmt, outputFile string) {
 	defer g.builder.Dispose()
 
 	g.defineBuiltInTypes()
+	g.declareEnums(stmts)
+	g.declareInterfaces(stmts)
+	g.declareStructs(stmts)
 	g.declareExternalFuncs()
 
 	for _, stmt := range stmts {
 		g.execute(stmt)
 	}
+	g.generatePendingBodies()
 
 	// g.module.Dump()
 	file, _ := os.OpenFile("build/"+outputFile+".ll", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
@@ -64,19 +163,168 @@ func (g *IRGenerator) GenerateIR(stmts []ast.Stmt, outputFile string) {
 func (g *IRGenerator) defineBuiltInTypes() {
 }
 
+func (g *IRGenerator) declareEnums(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		if enumStmt, ok := stmt.(*ast.EnumStmt); ok {
+			if _, exists := g.enums[enumStmt.Name.Lexeme]; exists {
+				panic(fmt.Sprintf("enum '%s' declared more than once (line %d)", enumStmt.Name.Lexeme, enumStmt.Name.Line))
+			}
+			g.enums[enumStmt.Name.Lexeme] = &enumInfo{variants: enumStmt.Variants}
+		}
+	}
+}
+
+// struct types are declared before anything else is generated so they can be used ahead of their declaration
+func (g *IRGenerator) declareStructs(stmts []ast.Stmt) {
+	structStmts := make([]*ast.StructStmt, 0)
+	for _, stmt := range stmts {
+		if structStmt, ok := stmt.(*ast.StructStmt); ok {
+			_, exists := g.structs[structStmt.Name.Lexeme]
+			if _, isGeneric := g.genericStructs[structStmt.Name.Lexeme]; exists || isGeneric {
+				panic(fmt.Sprintf("struct '%s' declared more than once (line %d)", structStmt.Name.Lexeme, structStmt.Name.Line))
+			}
+			if _, isInterface := g.interfaces[structStmt.Name.Lexeme]; isInterface {
+				panic(fmt.Sprintf("struct '%s' has the same name as an interface (line %d)", structStmt.Name.Lexeme, structStmt.Name.Line))
+			}
+			if _, isEnum := g.enums[structStmt.Name.Lexeme]; isEnum {
+				panic(fmt.Sprintf("struct '%s' has the same name as an enum (line %d)", structStmt.Name.Lexeme, structStmt.Name.Line))
+			}
+			if len(structStmt.TypeParams) > 0 {
+				// instantiated for each set of type arguments it is used with, see instantiateStruct
+				g.genericStructs[structStmt.Name.Lexeme] = structStmt
+				continue
+			}
+			g.structs[structStmt.Name.Lexeme] = &structInfo{
+				llvmType: g.ctx.StructCreateNamed(structStmt.Name.Lexeme),
+				methods:  make(map[string]*methodInfo),
+			}
+			structStmts = append(structStmts, structStmt)
+		}
+	}
+	// bodies are filled in afterwards since fields can refer to structs declared later on
+	for _, structStmt := range structStmts {
+		info := g.structs[structStmt.Name.Lexeme]
+		fieldTypes := make([]llvm.Type, 0)
+		for _, field := range structStmt.Fields {
+			field.Type = g.resolveType(field.Type)
+			info.fields = append(info.fields, field)
+			fieldTypes = append(fieldTypes, g.llvmTypeFromAstType(field.Type))
+		}
+		info.llvmType.StructSetBody(fieldTypes, false)
+	}
+	for _, structStmt := range structStmts {
+		for _, field := range g.structs[structStmt.Name.Lexeme].fields {
+			if g.containsStruct(field.Type, structStmt.Name.Lexeme, make(map[string]bool)) {
+				panic(fmt.Sprintf("struct '%s' contains itself through field '%s', which would make it infinitely large, use a pointer instead (line %d)", structStmt.Name.Lexeme, field.Name.Lexeme, field.Name.Line))
+			}
+		}
+	}
+	// method prototypes come last since their signatures can mention any struct
+	for _, structStmt := range structStmts {
+		info := g.structs[structStmt.Name.Lexeme]
+		receiver := ast.Type{Token: structStmt.Name, IsPointer: true}
+		for _, method := range structStmt.Methods {
+			stmt := g.resolveFn(method.Fn)
+			fn := g.declareFunction(mangleMethodName(structStmt.Name.Lexeme, method.Fn.Name.Lexeme), stmt, &receiver)
+			info.methods[method.Fn.Name.Lexeme] = &methodInfo{fn: fn, stmt: stmt, isPublic: method.IsPublic}
+		}
+	}
+	// interfaces a struct says it implements are checked here instead of wherever it is first used as one
+	for _, structStmt := range structStmts {
+		for _, iface := range structStmt.Implements {
+			g.checkImplements(ast.Type{Token: structStmt.Name}, iface, iface.Line)
+		}
+	}
+}
+
+// whether a value of the type holds a struct named name inside of it rather than pointing to one
+func (g *IRGenerator) containsStruct(type_ ast.Type, name string, seen map[string]bool) bool {
+	switch {
+	case type_.IsPointer || type_.IsSlice || type_.IsFunction:
+		return false
+	case type_.IsArray:
+		return g.containsStruct(*type_.Elem, name, seen)
+	case type_.IsTuple:
+		for _, elem := range type_.Elems {
+			if g.containsStruct(elem, name, seen) {
+				return true
+			}
+		}
+		return false
+	}
+	info, isStruct := g.structs[type_.Token.Lexeme]
+	if !isStruct || seen[type_.Token.Lexeme] {
+		return false
+	}
+	if type_.Token.Lexeme == name {
+		return true
+	}
+	seen[type_.Token.Lexeme] = true
+	for _, field := range info.fields {
+		if g.containsStruct(field.Type, name, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// methods live in the module as StructName.method so methods of different structs never collide
+func mangleMethodName(structName string, methodName string) string {
+	return structName + "." + methodName
+}
+
 func (g *IRGenerator) declareExternalFuncs() {
 	printfType := llvm.FunctionType(g.ctx.Int32Type(), []llvm.Type{llvm.PointerType(g.ctx.Int8Type(), 0)}, true)
 	printf := llvm.AddFunction(g.module, "printf", printfType)
 	g.environment.Define("printf")
-	g.environment.Set("printf", printf)
+	g.environment.Set("printf", variable{value: printf})
+
+	// backing memory for slices
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	llvm.AddFunction(g.module, "malloc", llvm.FunctionType(bytePtr, []llvm.Type{g.ctx.Int64Type()}, false))
+	llvm.AddFunction(g.module, "memcpy", llvm.FunctionType(bytePtr, []llvm.Type{bytePtr, bytePtr, g.ctx.Int64Type()}, false))
+
+	for _, builtin := range []string{"len", "append"} {
+		g.environment.Define(builtin)
+		g.environment.Set(builtin, variable{builtin: true})
+	}
+}
+
+func (g *IRGenerator) VisitStructStmt(stmt *ast.StructStmt) {
+	// the type and method prototypes were already created up front by declareStructs
+	if g.depth != 0 {
+		panic(fmt.Sprintf("struct '%s' must be declared at the top level (line %d)", stmt.Name.Lexeme, stmt.Name.Line))
+	}
+	if len(stmt.TypeParams) > 0 {
+		return
+	}
+	info := g.structs[stmt.Name.Lexeme]
+	receiver := ast.Type{Token: stmt.Name, IsPointer: true}
+	g.currentStruct = stmt.Name.Lexeme
+	for _, method := range stmt.Methods {
+		declared := info.methods[method.Fn.Name.Lexeme]
+		g.defineFunctionBody(declared.fn, declared.stmt, &receiver)
+	}
+	g.currentStruct = ""
+}
+
+func (g *IRGenerator) VisitEnumStmt(stmt *ast.EnumStmt) {
+	// enums have no runtime representation beyond their integer values, see declareEnums
+	if g.depth != 0 {
+		panic(fmt.Sprintf("enum '%s' must be declared at the top level (line %d)", stmt.Name.Lexeme, stmt.Name.Line))
+	}
 }
 
 func (g *IRGenerator) VisitBlockStmt(stmt *ast.BlockStmt) {
 	oldTable := g.environment
-	newTable := environment.NewEnvironment[llvm.Value](oldTable)
+	newTable := environment.NewEnvironment[variable](oldTable)
 	g.environment = newTable
 	g.depth++
 	for _, stmt_ := range stmt.Body {
+		// anything after a return, break or continue is unreachable
+		if g.isTerminated() {
+			break
+		}
 		g.execute(stmt_)
 	}
 	g.depth--
@@ -84,98 +332,354 @@ func (g *IRGenerator) VisitBlockStmt(stmt *ast.BlockStmt) {
 }
 
 func (g *IRGenerator) VisitFnStmt(stmt *ast.FnStmt) {
+	if len(stmt.TypeParams) > 0 {
+		// generated for each set of type arguments it gets called with, see genericCall
+		g.environment.Define(stmt.Name.Lexeme)
+		g.environment.Set(stmt.Name.Lexeme, variable{generic: stmt})
+		return
+	}
+	stmt = g.resolveFn(stmt)
+	fn := g.declareFunction(stmt.Name.Lexeme, stmt, nil)
+	g.environment.Define(stmt.Name.Lexeme)
+	g.environment.Set(stmt.Name.Lexeme, variable{value: fn, type_: stmt.Return, params: stmt.Params})
+	g.defineFunctionBody(fn, stmt, nil)
+}
+
+// adds the function prototype to the module. methods take a pointer to their receiver as an implicit first param
+func (g *IRGenerator) declareFunction(name string, stmt *ast.FnStmt, receiver *ast.Type) llvm.Value {
 	returnType := g.llvmTypeFromAstType(stmt.Return)
 	paramTypes := make([]llvm.Type, 0)
+	if receiver != nil {
+		paramTypes = append(paramTypes, g.llvmTypeFromAstType(*receiver))
+	}
 	for _, param := range stmt.Params {
 		paramType := g.llvmTypeFromAstType(param.Type)
 		paramTypes = append(paramTypes, paramType)
 	}
 	fnType := llvm.FunctionType(returnType, paramTypes, false)
-	fn := llvm.AddFunction(g.module, stmt.Name.Lexeme, fnType)
-	entry := llvm.AddBasicBlock(fn, "entry")
-	g.builder.SetInsertPointAtEnd(entry)
-
-	g.environment.Define(stmt.Name.Lexeme)
-	g.environment.Set(stmt.Name.Lexeme, fn)
+	return llvm.AddFunction(g.module, name, fnType)
+}
 
+func (g *IRGenerator) defineFunctionBody(fn llvm.Value, stmt *ast.FnStmt, receiver *ast.Type) {
 	prevEnv := g.environment
-	g.environment = environment.NewEnvironment[llvm.Value](prevEnv)
-	for i, param := range stmt.Params {
-		fnParam := fn.Param(i)
+	g.environment = environment.NewEnvironment[variable](prevEnv)
+	params := stmt.Params
+	if receiver != nil {
+		params = append([]ast.Param{{Name: scanner.Token{Type: scanner.IDENTIFIER, Lexeme: "self"}, Type: *receiver}}, params...)
+	}
+	g.generateBody(fn, stmt.Name, params, 0, stmt.Return, stmt.Body)
+	g.environment = prevEnv
+}
+
+// fills in fn with the body, defining the params in the current scope. firstParam skips over hidden
+// params that come before the declared ones, like the env of a closure, and name is where errors point to
+func (g *IRGenerator) generateBody(fn llvm.Value, name scanner.Token, params []ast.Param, firstParam int, returnType ast.Type, body ast.Stmt) {
+	entry := llvm.AddBasicBlock(fn, "entry")
+	g.builder.SetInsertPointAtEnd(entry)
+	g.currentFunction = fn
+	g.currentReturn = returnType
+	g.captured = capturedNames(body)
+	for i, param := range params {
+		fnParam := fn.Param(firstParam + i)
 		fnParam.SetName(param.Name.Lexeme)
+		// params get their own slot so they can be assigned to like any other local
+		paramPtr, boxed := g.createLocal(fnParam.Type(), param.Name.Lexeme)
+		g.builder.CreateStore(fnParam, paramPtr)
 		g.environment.Define(param.Name.Lexeme)
-		g.environment.Set(param.Name.Lexeme, fnParam)
+		g.environment.Set(param.Name.Lexeme, variable{value: paramPtr, type_: param.Type, function: fn, boxed: boxed})
+	}
+	g.execute(body)
+	last := g.builder.GetInsertBlock()
+	if !g.isTerminated() && fn.GlobalValueType().ReturnType().TypeKind() == llvm.VoidTypeKind {
+		g.builder.CreateRetVoid()
+	} else if !g.isTerminated() && returnType.IsErrorUnion && !hasPayload(returnType) {
+		// reaching the end of a function returning void? means it didn't fail
+		g.builder.CreateRet(g.errorUnionSuccess(returnType))
+	} else if !g.isTerminated() && last != entry && last.AsValue().FirstUse().IsNil() {
+		// nothing branches here when every path already returned, like an if and else that both do
+		g.builder.CreateUnreachable()
+	} else if !g.isTerminated() {
+		function := fmt.Sprintf("'%s'", name.Lexeme)
+		if name.Type == scanner.FN {
+			function = "an anonymous function"
+		}
+		panic(fmt.Sprintf("missing return at the end of %s, which returns '%s' (line %d)", function, typeName(returnType), name.Line))
 	}
-	g.currentFunction = fn
-	g.execute(stmt.Body)
-	g.environment = prevEnv
 }
 
 func (g *IRGenerator) VisitReturnStmt(stmt *ast.ReturnStmt) {
 	if stmt.Expression == nil {
+		if g.currentReturn.IsErrorUnion && !hasPayload(g.currentReturn) {
+			g.builder.CreateRet(g.errorUnionSuccess(g.currentReturn))
+			return
+		}
+		if g.currentReturn.Token.Lexeme != "void" || g.currentReturn.IsErrorUnion {
+			panic(fmt.Sprintf("function must return a value of type '%s' (line %d)", typeName(g.currentReturn), stmt.Keyword.Line))
+		}
 		g.builder.CreateRetVoid()
 		return
 	}
+	if _, isArrayLiteral := stmt.Expression.(*ast.ArrayLiteralExpr); isArrayLiteral {
+		// an array literal is never an error union itself, so it's always the payload
+		if g.currentReturn.IsErrorUnion {
+			g.builder.CreateRet(g.errorUnionValue(g.currentReturn, g.evaluateAs(stmt.Expression, *g.currentReturn.Elem, stmt.Keyword.Line)))
+		} else {
+			g.builder.CreateRet(g.evaluateAs(stmt.Expression, g.currentReturn, stmt.Keyword.Line))
+		}
+		return
+	}
 	res := g.evaluate(stmt.Expression)
+	resType := g.typeOf(stmt.Expression)
+	if g.currentReturn.IsErrorUnion && !resType.IsErrorUnion {
+		res = g.coerce(stmt.Expression, res, *g.currentReturn.Elem, stmt.Keyword.Line)
+		res = g.errorUnionValue(g.currentReturn, res)
+	} else {
+		res = g.coerce(stmt.Expression, res, g.currentReturn, stmt.Keyword.Line)
+	}
 	g.builder.CreateRet(res)
 }
 
 func (g *IRGenerator) VisitVarStmt(stmt *ast.VarStmt) {
 	// assuming type checking pass has already been done by this point
-	initializer := g.evaluate(stmt.Initializer)
-	// initializerVal := initializer
-	// if initializer.Type().TypeKind() == llvm.PointerTypeKind {
-	// 	initializerVal = g.builder.CreateLoad(initializer.AllocatedType(), initializer, "val")
-	// }
+	varType := g.resolveType(stmt.Type)
+	var initializer llvm.Value
+	if stmt.Initializer != nil && varType.Token.Lexeme != "auto" {
+		initializer = g.evaluateAs(stmt.Initializer, varType, stmt.Name.Line)
+	} else if stmt.Initializer != nil {
+		initializer = g.evaluate(stmt.Initializer)
+		varType = g.typeOf(stmt.Initializer)
+		if isNil(varType) {
+			panic(fmt.Sprintf("cannot infer the type of '%s' from nil (line %d)", stmt.Name.Lexeme, stmt.Name.Line))
+		}
+		if varType.Token.Lexeme == "void" && !varType.IsPointer {
+			panic(fmt.Sprintf("cannot declare '%s' from a value of type '%s' since it holds nothing (line %d)", stmt.Name.Lexeme, typeName(varType), stmt.Name.Line))
+		}
+		initializer = g.coerce(stmt.Initializer, initializer, varType, stmt.Name.Line)
+	}
 
-	var llvmType llvm.Type
-	if stmt.Type.Token.Lexeme == "auto" {
-		llvmType = initializer.Type()
-	} else {
-		llvmType = g.llvmTypeFromAstType(stmt.Type)
+	if stmt.Initializer == nil {
+		initializer = llvm.ConstNull(g.llvmTypeFromAstType(varType))
 	}
+	g.declareVariable(stmt.Name, varType, initializer, stmt.IsConst)
+}
+
+// defines a variable in the current scope holding initializer, as a global at the top level and a local anywhere else
+func (g *IRGenerator) declareVariable(name scanner.Token, varType ast.Type, initializer llvm.Value, isConst bool) {
+	llvmType := g.llvmTypeFromAstType(varType)
 	var varPtr llvm.Value
+	var boxed bool
 	if g.depth == 0 {
-		varPtr = llvm.AddGlobal(g.module, llvmType, stmt.Name.Lexeme)
+		varPtr = llvm.AddGlobal(g.module, llvmType, name.Lexeme)
 		varPtr.SetInitializer(initializer)
+		if isConst {
+			// top level consts become constant globals so llvm can fold them into their uses
+			if !initializer.IsConstant() {
+				panic(fmt.Sprintf("const '%s' must be initialized with a constant value (line %d)", name.Lexeme, name.Line))
+			}
+			varPtr.SetGlobalConstant(true)
+		}
 	} else {
-		varPtr = g.builder.CreateAlloca(llvmType, stmt.Name.Lexeme)
+		varPtr, boxed = g.createLocal(llvmType, name.Lexeme)
 		g.builder.CreateStore(initializer, varPtr)
 	}
-	g.environment.Define(stmt.Name.Lexeme)
-	g.environment.Set(stmt.Name.Lexeme, varPtr)
+	g.environment.Define(name.Lexeme)
+	local := variable{value: varPtr, type_: varType, isConst: isConst, boxed: boxed}
+	if g.depth > 0 {
+		local.function = g.currentFunction
+	}
+	g.environment.Set(name.Lexeme, local)
 }
 
 func (g *IRGenerator) VisitIfStmt(stmt *ast.IfStmt) {
-	conditionVal := g.evaluate(stmt.IfCondition)
+	conditionVal := g.evaluateCondition(stmt.IfCondition, stmt.Keyword)
 	ifBlock := llvm.AddBasicBlock(g.currentFunction, "ifBlock")
 	elseBlock := llvm.AddBasicBlock(g.currentFunction, "elseBlock")
 	mergeBlock := llvm.AddBasicBlock(g.currentFunction, "mergeBlock")
 
 	g.builder.CreateCondBr(conditionVal, ifBlock, elseBlock)
 
+	// pointers checked against nil are non-nullable in whichever branch the check rules nil out of
 	g.builder.SetInsertPointAtEnd(ifBlock)
+	prevEnv := g.narrow(nonNilWhenTrue(stmt.IfCondition))
 	g.execute(stmt.IfBlock)
-	g.builder.CreateBr(mergeBlock)
+	g.environment = prevEnv
+	ifReturns := g.isTerminated()
+	g.branchTo(mergeBlock)
 	g.builder.SetInsertPointAtEnd(elseBlock)
-    for i, _ := range stmt.ElifConditions {
-        elifBlock := llvm.AddBasicBlock(g.currentFunction, fmt.Sprint("elifBlock-%d", i))
-        elifElseBlock := llvm.AddBasicBlock(g.currentFunction, fmt.Sprint("elifElseBlock-%d", i))
-
-        elifCondition := g.evaluate(stmt.ElifConditions[i])
-        elifStmts := stmt.ElifBlocks[i]
-
-        g.builder.CreateCondBr(elifCondition, elifBlock, elifElseBlock)
-        g.builder.SetInsertPointAtEnd(elifBlock)
-        g.execute(elifStmts)
-        g.builder.CreateBr(mergeBlock)
-        g.builder.SetInsertPointAtEnd(elifElseBlock)
-    }
+	g.narrow(nonNilWhenFalse(stmt.IfCondition))
+	for i := range stmt.ElifConditions {
+		elifBlock := llvm.AddBasicBlock(g.currentFunction, fmt.Sprintf("elifBlock-%d", i))
+		elifElseBlock := llvm.AddBasicBlock(g.currentFunction, fmt.Sprintf("elifElseBlock-%d", i))
+
+		elifCondition := g.evaluateCondition(stmt.ElifConditions[i], stmt.ElifKeywords[i])
+		elifStmts := stmt.ElifBlocks[i]
+
+		g.builder.CreateCondBr(elifCondition, elifBlock, elifElseBlock)
+		g.builder.SetInsertPointAtEnd(elifBlock)
+		elifEnv := g.narrow(nonNilWhenTrue(stmt.ElifConditions[i]))
+		g.execute(elifStmts)
+		g.environment = elifEnv
+		g.branchTo(mergeBlock)
+		g.builder.SetInsertPointAtEnd(elifElseBlock)
+		g.narrow(nonNilWhenFalse(stmt.ElifConditions[i]))
+	}
 	if stmt.ElseBlock != nil {
 		g.execute(stmt.ElseBlock)
 	}
-	g.builder.CreateBr(mergeBlock)
+	g.environment = prevEnv
+	g.branchTo(mergeBlock)
 	g.builder.SetInsertPointAtEnd(mergeBlock)
+	// if p == nil { return; } leaves p non-nil for the rest of the enclosing block
+	if ifReturns && len(stmt.ElifConditions) == 0 && stmt.ElseBlock == nil {
+		g.narrowScope(nonNilWhenFalse(stmt.IfCondition))
+	}
+}
+
+func (g *IRGenerator) evaluateCondition(condition ast.Expr, keyword scanner.Token) llvm.Value {
+	conditionVal := g.evaluate(condition)
+	if conditionType := g.typeOf(condition); !isPrimitive(conditionType, "bool") {
+		panic(fmt.Sprintf("%s condition must be a 'bool', got '%s' (line %d)", keyword.Lexeme, typeName(conditionType), keyword.Line))
+	}
+	return conditionVal
+}
+
+// a loop whose condition is always true, like while true { }, only exits through a break. the exit isn't
+// branched to otherwise, so code after the loop is known to be unreachable
+func (g *IRGenerator) loopBranch(conditionVal llvm.Value, bodyBlock llvm.BasicBlock, exitBlock llvm.BasicBlock) {
+	if !conditionVal.IsAConstantInt().IsNil() && conditionVal.ZExtValue() == 1 {
+		g.builder.CreateBr(bodyBlock)
+		return
+	}
+	g.builder.CreateCondBr(conditionVal, bodyBlock, exitBlock)
+}
+
+func (g *IRGenerator) VisitWhileStmt(stmt *ast.WhileStmt) {
+	headerBlock := llvm.AddBasicBlock(g.currentFunction, "whileHeader")
+	bodyBlock := llvm.AddBasicBlock(g.currentFunction, "whileBody")
+	exitBlock := llvm.AddBasicBlock(g.currentFunction, "whileExit")
+
+	g.builder.CreateBr(headerBlock)
+	g.builder.SetInsertPointAtEnd(headerBlock)
+	conditionVal := g.evaluateCondition(stmt.Condition, stmt.Keyword)
+	g.loopBranch(conditionVal, bodyBlock, exitBlock)
+
+	g.builder.SetInsertPointAtEnd(bodyBlock)
+	g.loops = append(g.loops, loopBlocks{continueBlock: headerBlock, breakBlock: exitBlock})
+	bodyEnv := g.narrow(nonNilWhenTrue(stmt.Condition))
+	g.execute(stmt.Body)
+	g.environment = bodyEnv
+	g.loops = g.loops[:len(g.loops)-1]
+	g.branchTo(headerBlock)
+
+	g.builder.SetInsertPointAtEnd(exitBlock)
+}
+
+func (g *IRGenerator) VisitForStmt(stmt *ast.ForStmt) {
+	// the loop variable only lives for the duration of the loop
+	prevEnv := g.environment
+	g.environment = environment.NewEnvironment[variable](prevEnv)
+	g.depth++
+
+	g.execute(stmt.Initializer)
+
+	headerBlock := llvm.AddBasicBlock(g.currentFunction, "forHeader")
+	bodyBlock := llvm.AddBasicBlock(g.currentFunction, "forBody")
+	incrementBlock := llvm.AddBasicBlock(g.currentFunction, "forIncrement")
+	exitBlock := llvm.AddBasicBlock(g.currentFunction, "forExit")
+
+	g.builder.CreateBr(headerBlock)
+	g.builder.SetInsertPointAtEnd(headerBlock)
+	if stmt.Condition != nil {
+		conditionVal := g.evaluateCondition(stmt.Condition, stmt.Keyword)
+		g.loopBranch(conditionVal, bodyBlock, exitBlock)
+	} else {
+		g.builder.CreateBr(bodyBlock)
+	}
+
+	g.builder.SetInsertPointAtEnd(bodyBlock)
+	g.loops = append(g.loops, loopBlocks{continueBlock: incrementBlock, breakBlock: exitBlock})
+	g.execute(stmt.Body)
+	g.loops = g.loops[:len(g.loops)-1]
+	g.branchTo(incrementBlock)
+
+	g.builder.SetInsertPointAtEnd(incrementBlock)
+	if stmt.Increment != nil {
+		g.evaluate(stmt.Increment)
+	}
+	g.builder.CreateBr(headerBlock)
+
+	g.builder.SetInsertPointAtEnd(exitBlock)
+	g.depth--
+	g.environment = prevEnv
+}
+
+func (g *IRGenerator) VisitForRangeStmt(stmt *ast.ForRangeStmt) {
+	// bounds are only evaluated once, before entering the loop
+	start := g.evaluate(stmt.Start)
+	end := g.evaluate(stmt.End)
+	// a literal bound takes the type of the other one, so 0..len(s) counts in i64
+	rangeType := g.typeOf(stmt.Start)
+	if _, isLiteral := literalValue(stmt.Start); isLiteral {
+		rangeType = g.typeOf(stmt.End)
+	}
+	start = g.coerce(stmt.Start, start, rangeType, stmt.Variable.Line)
+	end = g.coerce(stmt.End, end, rangeType, stmt.Variable.Line)
+
+	prevEnv := g.environment
+	g.environment = environment.NewEnvironment[variable](prevEnv)
+	g.depth++
+
+	indexType := start.Type()
+	indexPtr, boxed := g.createLocal(indexType, stmt.Variable.Lexeme)
+	g.builder.CreateStore(start, indexPtr)
+	g.environment.Define(stmt.Variable.Lexeme)
+	g.environment.Set(stmt.Variable.Lexeme, variable{value: indexPtr, type_: rangeType, function: g.currentFunction, boxed: boxed})
+
+	headerBlock := llvm.AddBasicBlock(g.currentFunction, "rangeHeader")
+	bodyBlock := llvm.AddBasicBlock(g.currentFunction, "rangeBody")
+	incrementBlock := llvm.AddBasicBlock(g.currentFunction, "rangeIncrement")
+	exitBlock := llvm.AddBasicBlock(g.currentFunction, "rangeExit")
+
+	g.builder.CreateBr(headerBlock)
+	g.builder.SetInsertPointAtEnd(headerBlock)
+	index := g.builder.CreateLoad(indexType, indexPtr, "index")
+	inRange := g.compare(isFloat(rangeType), isUnsigned(rangeType), llvm.FloatOLT, llvm.IntULT, llvm.IntSLT, index, end, "inRange")
+	g.builder.CreateCondBr(inRange, bodyBlock, exitBlock)
+
+	g.builder.SetInsertPointAtEnd(bodyBlock)
+	g.loops = append(g.loops, loopBlocks{continueBlock: incrementBlock, breakBlock: exitBlock})
+	g.execute(stmt.Body)
+	g.loops = g.loops[:len(g.loops)-1]
+	g.branchTo(incrementBlock)
+
+	g.builder.SetInsertPointAtEnd(incrementBlock)
+	index = g.builder.CreateLoad(indexType, indexPtr, "index")
+	var next llvm.Value
+	if isFloat(rangeType) {
+		next = g.builder.CreateFAdd(index, llvm.ConstFloat(indexType, 1), "next")
+	} else {
+		next = g.builder.CreateAdd(index, llvm.ConstInt(indexType, 1, false), "next")
+	}
+	g.builder.CreateStore(next, indexPtr)
+	g.builder.CreateBr(headerBlock)
+
+	g.builder.SetInsertPointAtEnd(exitBlock)
+	g.depth--
+	g.environment = prevEnv
+}
+
+func (g *IRGenerator) VisitBreakStmt(stmt *ast.BreakStmt) {
+	if len(g.loops) == 0 {
+		panic(fmt.Sprintf("'break' outside of loop on line %d", stmt.Keyword.Line))
+	}
+	g.builder.CreateBr(g.loops[len(g.loops)-1].breakBlock)
+}
+
+func (g *IRGenerator) VisitContinueStmt(stmt *ast.ContinueStmt) {
+	if len(g.loops) == 0 {
+		panic(fmt.Sprintf("'continue' outside of loop on line %d", stmt.Keyword.Line))
+	}
+	g.builder.CreateBr(g.loops[len(g.loops)-1].continueBlock)
 }
 
 func (g *IRGenerator) VisitExpressionStmt(stmt *ast.ExpressionStmt) {
@@ -186,19 +690,19 @@ func (g *IRGenerator) VisitPrintStmt(stmt *ast.PrintStmt) {
 }
 
 func (g *IRGenerator) VisitNumberExpr(expr *ast.NumberExpr) llvm.Value {
-	val := llvm.ConstFloat(g.ctx.DoubleType(), expr.Value)
+	if expr.Suffix != nil {
+		g.exprTypes[expr] = *expr.Suffix
+		return g.numericConstant(expr.Value, *expr.Suffix, expr.Suffix.Token.Line)
+	}
+	float, _ := constant.Float64Val(expr.Value)
+	val := llvm.ConstFloat(g.ctx.DoubleType(), float)
+	g.exprTypes[expr] = primitiveType("number")
 	return val
 }
 
-func (g *IRGenerator) VisitStringExpr(expr *ast.StringExpr) llvm.Value {
-	str := llvm.ConstString(expr.Value, true)
-	strPtr := g.builder.CreateAlloca(str.Type(), "")
-	g.builder.CreateStore(str, strPtr)
-	return strPtr
-}
-
 func (g *IRGenerator) VisitCharExpr(expr *ast.CharExpr) llvm.Value {
 	char := llvm.ConstInt(g.ctx.Int8Type(), uint64(expr.Value), true)
+	g.exprTypes[expr] = primitiveType("char")
 	return char
 }
 
@@ -210,80 +714,595 @@ func (g *IRGenerator) VisitBoolExpr(expr *ast.BoolExpr) llvm.Value {
 		boolVal = 0
 	}
 	val := llvm.ConstInt(g.ctx.Int1Type(), boolVal, false)
+	g.exprTypes[expr] = primitiveType("bool")
 	return val
 }
 
 func (g *IRGenerator) VisitIdentifierExpr(expr *ast.IdentifierExpr) llvm.Value {
 	name := expr.Value.Lexeme
-	varPtr, exists := g.environment.Get(name)
+	variable, exists := g.environment.Get(name)
 	if !exists {
+		for enumName, enum := range g.enums {
+			if _, isVariant := enum.variantIndex(name); isVariant {
+				panic(fmt.Sprintf("enum variant '%s' must be accessed as '%s.%s' (line %d)", name, enumName, name, expr.Value.Line))
+			}
+		}
 		panic("trying to reference undefined identifier")
 	}
+	if variable.builtin {
+		panic(fmt.Sprintf("builtin '%s' can only be called (line %d)", name, expr.Value.Line))
+	}
+	if variable.generic != nil {
+		panic(fmt.Sprintf("generic function '%s' can only be called, like %s(x) or %s[i32](x) (line %d)", name, name, name, expr.Value.Line))
+	}
+	if !variable.value.IsAFunction().IsNil() {
+		// calls to named functions never get here, this is the function being used as a value
+		return g.functionValue(expr, variable)
+	}
+	if !variable.function.IsNil() && variable.function != g.currentFunction {
+		variable = g.capture(expr.Value, variable)
+	}
+	g.exprTypes[expr] = variable.type_
 
-	if !varPtr.IsAFunction().IsNil() || g.identifierAddress || !varPtr.IsAArgument().IsNil() {
-		return varPtr
+	if g.identifierAddress {
+		return variable.value
 	}
 
-	varVal := g.builder.CreateLoad(varPtr.AllocatedType(), varPtr, "")
+	varVal := g.builder.CreateLoad(g.llvmTypeFromAstType(variable.type_), variable.value, "")
 	return varVal
 }
 
 func (g *IRGenerator) VisitGroupingExpr(expr *ast.GroupingExpr) llvm.Value {
-	return llvm.Value{}
+	val := g.evaluate(expr.Expression)
+	g.exprTypes[expr] = g.typeOf(expr.Expression)
+	return val
 }
 
 func (g *IRGenerator) VisitCallExpr(expr *ast.CallExpr) llvm.Value {
-	fn := g.evaluate(expr.Callee)
+	if get, ok := expr.Callee.(*ast.GetExpr); ok {
+		return g.methodCall(expr, get)
+	}
+	g.typeArgsFromIndex(expr)
+	identifier, isIdentifier := expr.Callee.(*ast.IdentifierExpr)
+	if !isIdentifier {
+		return g.callValue(expr, g.evaluate(expr.Callee), g.typeOf(expr.Callee))
+	}
+	callee, exists := g.environment.Get(identifier.Value.Lexeme)
+	if len(expr.TypeArgs) > 0 && (!exists || callee.generic == nil) {
+		panic(fmt.Sprintf("'%s' isn't a generic function so it takes no type arguments (line %d)", identifier.Value.Lexeme, expr.Paren.Line))
+	}
+	if exists && callee.builtin {
+		return g.builtinCall(expr, identifier.Value)
+	}
+	if exists && identifier.Value.Lexeme == "printf" && len(expr.Args) > 0 {
+		if interpolation, ok := expr.Args[0].(*ast.InterpolationExpr); ok {
+			return g.printInterpolation(expr, interpolation, callee)
+		}
+	}
+	if exists && callee.generic != nil {
+		return g.genericCall(expr, identifier.Value, callee.generic, nil)
+	}
+	if !exists || callee.value.IsAFunction().IsNil() {
+		// a variable holding a function value
+		return g.callValue(expr, g.evaluate(expr.Callee), g.typeOf(expr.Callee))
+	}
+	fn := callee.value
+	params := callee.params
 	args := make([]llvm.Value, 0)
-	for _, arg := range expr.Args {
+	for i, arg := range expr.Args {
+		if i < len(params) {
+			args = append(args, g.evaluateAs(arg, params[i].Type, expr.Paren.Line))
+			continue
+		}
 		argTmp := g.evaluate(arg)
+		if fn.GlobalValueType().IsFunctionVarArg() {
+			argTmp = g.promoteVarArg(argTmp, g.typeOf(arg))
+		}
 		args = append(args, argTmp)
 	}
-	return g.builder.CreateCall(fn.GlobalValueType(), fn, args, "callRes")
+	g.exprTypes[expr] = callee.type_
+	return g.createCall(fn, args)
+}
+
+func (g *IRGenerator) createCall(fn llvm.Value, args []llvm.Value) llvm.Value {
+	fnType := fn.GlobalValueType()
+	name := "callRes"
+	if fnType.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		// void results can't be named
+		name = ""
+	}
+	return g.builder.CreateCall(fnType, fn, args, name)
+}
+
+// obj.method(args) calls StructName.method(&obj, args)
+func (g *IRGenerator) methodCall(expr *ast.CallExpr, callee *ast.GetExpr) llvm.Value {
+	receiverPtr, receiverType := g.evaluateAggregateAddress(callee.Object, callee.Name.Line)
+	if g.isInterface(receiverType) {
+		ifaceVal := g.builder.CreateLoad(g.interfaceType(), receiverPtr, "")
+		return g.interfaceCall(expr, callee, ifaceVal, receiverType)
+	}
+	if !g.isStruct(receiverType) {
+		panic(fmt.Sprintf("cannot call method '%s' on a value of type '%s' since it isn't a struct (line %d)", callee.Name.Lexeme, typeName(receiverType), callee.Name.Line))
+	}
+	info := g.lookupStruct(receiverType.Token)
+	method, exists := info.methods[callee.Name.Lexeme]
+	if index, isField := info.fieldIndex(callee.Name.Lexeme); !exists && isField && info.fields[index].Type.IsFunction {
+		// a field holding a function value is called without a receiver
+		g.checkVisibility(receiverType.Token.Lexeme, callee.Name, info.fields[index].IsPublic)
+		fieldPtr := g.builder.CreateStructGEP(info.llvmType, receiverPtr, index, callee.Name.Lexeme+"Ptr")
+		closure := g.builder.CreateLoad(g.closureType(), fieldPtr, callee.Name.Lexeme)
+		return g.callValue(expr, closure, info.fields[index].Type)
+	}
+	if !exists {
+		panic(fmt.Sprintf("struct '%s' has no method '%s' (line %d)", receiverType.Token.Lexeme, callee.Name.Lexeme, callee.Name.Line))
+	}
+	g.checkVisibility(receiverType.Token.Lexeme, callee.Name, method.isPublic)
+	if !g.typeOf(callee.Object).IsPointer && g.modifiesReceiver(receiverType, method) {
+		g.checkMutable(callee.Object, fmt.Sprintf("call '%s', which changes its receiver, on", callee.Name.Lexeme))
+	}
+
+	args := []llvm.Value{receiverPtr}
+	for i, arg := range expr.Args {
+		if i < len(method.stmt.Params) {
+			args = append(args, g.evaluateAs(arg, method.stmt.Params[i].Type, expr.Paren.Line))
+		} else {
+			args = append(args, g.evaluate(arg))
+		}
+	}
+	g.exprTypes[expr] = method.stmt.Return
+	return g.createCall(method.fn, args)
+}
+
+// operators like += and -= map to the arithmetic they apply before storing
+var compoundAssignOperators = map[scanner.TokenType]scanner.TokenType{
+	scanner.PLUS_ASSIGN:  scanner.PLUS,
+	scanner.MINUS_ASSIGN: scanner.MINUS,
+	scanner.STAR_ASSIGN:  scanner.STAR,
+	scanner.SLASH_ASSIGN: scanner.SLASH,
+	scanner.MOD_ASSIGN:   scanner.MOD,
+}
+
+func (g *IRGenerator) VisitGetExpr(expr *ast.GetExpr) llvm.Value {
+	if enum, enumName, ok := g.enumAccess(expr); ok {
+		index, exists := enum.variantIndex(expr.Name.Lexeme)
+		if !exists {
+			panic(fmt.Sprintf("enum '%s' has no variant '%s' (line %d)", enumName.Lexeme, expr.Name.Lexeme, expr.Name.Line))
+		}
+		g.exprTypes[expr] = ast.Type{Token: scanner.Token{Type: scanner.TYPE, Lexeme: enumName.Lexeme, Line: enumName.Line}}
+		return llvm.ConstInt(g.ctx.Int32Type(), uint64(index), false)
+	}
+	fieldPtr := g.evaluateAddress(expr)
+	return g.builder.CreateLoad(g.llvmTypeFromAstType(g.typeOf(expr)), fieldPtr, expr.Name.Lexeme)
+}
+
+func (g *IRGenerator) VisitIndexExpr(expr *ast.IndexExpr) llvm.Value {
+	elementPtr := g.evaluateAddress(expr)
+	return g.builder.CreateLoad(g.llvmTypeFromAstType(g.typeOf(expr)), elementPtr, "element")
+}
+
+func (g *IRGenerator) VisitArrayLiteralExpr(expr *ast.ArrayLiteralExpr) llvm.Value {
+	values := make([]llvm.Value, 0)
+	for _, element := range expr.Elements {
+		values = append(values, g.evaluate(element))
+	}
+	// literals take the type of the first element that has one, so [x, 1] works for any numeric x
+	elemType := g.typeOf(expr.Elements[0])
+	for _, element := range expr.Elements {
+		if _, isLiteral := literalValue(element); !isLiteral {
+			elemType = g.typeOf(element)
+			break
+		}
+	}
+	for i, element := range expr.Elements {
+		values[i] = g.coerce(element, values[i], elemType, expr.Bracket.Line)
+	}
+	llvmElemType := g.llvmTypeFromAstType(elemType)
+	for _, value := range values {
+		if value.Type() != llvmElemType {
+			panic(fmt.Sprintf("array literal elements must all have the same type (line %d)", expr.Bracket.Line))
+		}
+	}
+
+	arrayVal := llvm.ConstNull(llvm.ArrayType(llvmElemType, len(values)))
+	for i, value := range values {
+		arrayVal = g.builder.CreateInsertValue(arrayVal, value, i, "")
+	}
+	g.exprTypes[expr] = ast.Type{Token: expr.Bracket, IsArray: true, Length: len(values), Elem: &elemType}
+	return arrayVal
+}
+
+func (g *IRGenerator) VisitStructLiteralExpr(expr *ast.StructLiteralExpr) llvm.Value {
+	return g.structLiteral(expr, "")
+}
+
+// instance is the instance of a generic struct the literal builds when it's known from where the literal is
+// stored, otherwise the type arguments are taken from the literal or inferred from its field values
+func (g *IRGenerator) structLiteral(expr *ast.StructLiteralExpr, instance string) llvm.Value {
+	name := expr.Name
+	values := make([]llvm.Value, len(expr.Values))
+	if len(expr.TypeArgs) > 0 {
+		name = g.resolveType(ast.Type{Token: name, TypeArgs: expr.TypeArgs}).Token
+	} else if instance != "" {
+		name.Lexeme = instance
+	} else if generic, isGeneric := g.genericStructs[name.Lexeme]; isGeneric {
+		name.Lexeme, values = g.genericStructLiteral(expr, generic)
+	}
+	info := g.lookupStruct(name)
+	structVal := llvm.ConstNull(info.llvmType)
+	for i, field := range expr.Fields {
+		index, exists := info.fieldIndex(field.Lexeme)
+		if !exists {
+			panic(fmt.Sprintf("struct '%s' has no field '%s' (line %d)", name.Lexeme, field.Lexeme, field.Line))
+		}
+		g.checkVisibility(name.Lexeme, field, info.fields[index].IsPublic)
+		var value llvm.Value
+		if values[i].IsNil() {
+			value = g.evaluateAs(expr.Values[i], info.fields[index].Type, field.Line)
+		} else {
+			value = g.coerce(expr.Values[i], values[i], info.fields[index].Type, field.Line)
+		}
+		structVal = g.builder.CreateInsertValue(structVal, value, index, "")
+	}
+	g.exprTypes[expr] = ast.Type{Token: name}
+	return structVal
+}
+
+func (g *IRGenerator) VisitAssignExpr(expr *ast.AssignExpr) llvm.Value {
+	_, isArrayLiteral := expr.Value.(*ast.ArrayLiteralExpr)
+	_, isStructLiteral := expr.Value.(*ast.StructLiteralExpr)
+	if (isArrayLiteral || isStructLiteral) && expr.Operator.Type == scanner.ASSIGN {
+		// literals take their element type or type arguments from the target, so the target goes first
+		targetPtr := g.evaluateAddress(expr.Target)
+		g.checkMutable(expr.Target, "assign to")
+		value := g.evaluateAs(expr.Value, g.typeOf(expr.Target), expr.Operator.Line)
+		g.builder.CreateStore(value, targetPtr)
+		g.exprTypes[expr] = g.typeOf(expr.Target)
+		return value
+	}
+	if expr.Operator.Type == scanner.ASSIGN {
+		value := g.evaluate(expr.Value)
+		g.widen(expr.Target, g.typeOf(expr.Value), expr.Operator.Line)
+		targetPtr := g.evaluateAddress(expr.Target)
+		g.checkMutable(expr.Target, "assign to")
+		value = g.coerce(expr.Value, value, g.typeOf(expr.Target), expr.Operator.Line)
+		g.builder.CreateStore(value, targetPtr)
+		g.exprTypes[expr] = g.typeOf(expr.Target)
+		return value
+	}
+
+	targetPtr := g.evaluateAddress(expr.Target)
+	g.checkMutable(expr.Target, "assign to")
+	targetType := g.typeOf(expr.Target)
+	current := g.builder.CreateLoad(g.llvmTypeFromAstType(targetType), targetPtr, "")
+	value := g.evaluate(expr.Value)
+	operator := expr.Operator
+	operator.Type = compoundAssignOperators[expr.Operator.Type]
+	checkTypeParamOperator(operator, targetType)
+	if !isNumeric(targetType) && !isString(targetType) {
+		panic(fmt.Sprintf("operator '%s' needs a numeric target, got '%s' (line %d)", expr.Operator.Lexeme, typeName(targetType), expr.Operator.Line))
+	}
+	g.checkOperandType(operator, targetType)
+	value = g.coerce(expr.Value, value, targetType, expr.Operator.Line)
+	if isString(targetType) {
+		checkStringOperands(operator, targetType, g.typeOf(expr.Value))
+	}
+	result := g.binaryOp(operator, targetType, current, value)
+	g.builder.CreateStore(result, targetPtr)
+	g.exprTypes[expr] = targetType
+	return result
+}
+
+func (g *IRGenerator) VisitIncrementExpr(expr *ast.IncrementExpr) llvm.Value {
+	targetPtr := g.evaluateAddress(expr.Target)
+	g.checkMutable(expr.Target, fmt.Sprintf("apply '%s' to", expr.Operator.Lexeme))
+	targetType := g.typeOf(expr.Target)
+	checkTypeParamOperator(expr.Operator, targetType)
+	if !isNumeric(targetType) {
+		panic(fmt.Sprintf("operator '%s' needs a numeric target, got '%s' (line %d)", expr.Operator.Lexeme, typeName(targetType), expr.Operator.Line))
+	}
+	llvmType := g.llvmTypeFromAstType(targetType)
+	current := g.builder.CreateLoad(llvmType, targetPtr, "")
+
+	var updated llvm.Value
+	if isFloat(targetType) {
+		one := llvm.ConstFloat(llvmType, 1)
+		if expr.Operator.Type == scanner.PLUSPLUS {
+			updated = g.builder.CreateFAdd(current, one, "increment")
+		} else {
+			updated = g.builder.CreateFSub(current, one, "decrement")
+		}
+	} else {
+		one := llvm.ConstInt(llvmType, 1, false)
+		if expr.Operator.Type == scanner.PLUSPLUS {
+			updated = g.builder.CreateAdd(current, one, "increment")
+		} else {
+			updated = g.builder.CreateSub(current, one, "decrement")
+		}
+	}
+	g.builder.CreateStore(updated, targetPtr)
+	g.exprTypes[expr] = targetType
+
+	if expr.IsPrefix {
+		return updated
+	}
+	return current
 }
 
 func (g *IRGenerator) VisitBinaryExpr(expr *ast.BinaryExpr) llvm.Value {
 	lhsVal := expr.Left.Visit(g)
 	rhsVal := expr.Right.Visit(g)
+	checkTypeParamOperator(expr.Operator, g.typeOf(expr.Left))
+	checkTypeParamOperator(expr.Operator, g.typeOf(expr.Right))
+	if _, isEnum := g.enums[g.typeOf(expr.Left).Token.Lexeme]; isEnum {
+		g.checkEnumComparison(expr)
+	}
+	// nil takes on the pointer type of whatever it's compared with
+	if isNil(g.typeOf(expr.Left)) || isNil(g.typeOf(expr.Right)) {
+		if expr.Operator.Type != scanner.EQUAL && expr.Operator.Type != scanner.NOT_EQUAL {
+			panic(fmt.Sprintf("nil can only be compared with '==' and '!=' (line %d)", expr.Operator.Line))
+		}
+		if isNil(g.typeOf(expr.Left)) {
+			lhsVal = g.coerce(expr.Left, lhsVal, g.nullableTypeOf(expr.Right, expr.Operator), expr.Operator.Line)
+		}
+		if isNil(g.typeOf(expr.Right)) {
+			rhsVal = g.coerce(expr.Right, rhsVal, g.nullableTypeOf(expr.Left, expr.Operator), expr.Operator.Line)
+		}
+	}
+	if isString(g.typeOf(expr.Left)) || isString(g.typeOf(expr.Right)) {
+		checkStringOperands(expr.Operator, g.typeOf(expr.Left), g.typeOf(expr.Right))
+	}
+	if isBitwiseOperator(expr.Operator.Type) {
+		// arithmetic on integer literals like 1 << 4 is folded, there is no float version to fall back to
+		if literal, isLiteral := literalValue(expr); isLiteral {
+			g.exprTypes[expr] = foldedIntegerType(literal)
+			return g.numericConstant(literal, g.exprTypes[expr], expr.Operator.Line)
+		}
+	}
+	lhsVal = g.unifyLiteral(expr.Left, lhsVal, expr.Right, expr.Operator.Line)
+	rhsVal = g.unifyLiteral(expr.Right, rhsVal, expr.Left, expr.Operator.Line)
+	operandType := g.typeOf(expr.Left)
+	if rightType := g.typeOf(expr.Right); isNumeric(operandType) && isNumeric(rightType) && !sameNumericType(operandType, rightType) {
+		panic(fmt.Sprintf("mismatched types '%s' and '%s' for '%s', use 'as' to convert one of them (line %d)", numericName(operandType), numericName(rightType), expr.Operator.Lexeme, expr.Operator.Line))
+	}
+	checkCharAndBoolOperands(expr.Operator, operandType, g.typeOf(expr.Right))
+	if isBitwiseOperator(expr.Operator.Type) {
+		g.checkBitwiseOperands(expr.Operator, operandType)
+	}
+	g.checkOperandType(expr.Operator, operandType)
 	switch expr.Operator.Type {
+	case scanner.LESS, scanner.LESS_EQ, scanner.GREATER, scanner.GREATER_EQ, scanner.EQUAL, scanner.NOT_EQUAL:
+		g.exprTypes[expr] = primitiveType("bool")
+	default:
+		g.exprTypes[expr] = operandType
+	}
+	return g.binaryOp(expr.Operator, operandType, lhsVal, rhsVal)
+}
+
+// structs, tuples, arrays and the like have no operators, and pointers can only be compared for equality
+func (g *IRGenerator) checkOperandType(operator scanner.Token, operandType ast.Type) {
+	isEquality := operator.Type == scanner.EQUAL || operator.Type == scanner.NOT_EQUAL
+	if operandType.IsPointer && !isEquality {
+		panic(fmt.Sprintf("operator '%s' is not supported on pointer '%s' (line %d)", operator.Lexeme, typeName(operandType), operator.Line))
+	}
+	if !operandType.IsPointer && (operandType.IsArray || operandType.IsSlice || operandType.IsErrorUnion || operandType.IsFunction || operandType.IsTuple || g.isStruct(operandType) || g.isInterface(operandType)) {
+		panic(fmt.Sprintf("operator '%s' is not supported on '%s' (line %d)", operator.Lexeme, typeName(operandType), operator.Line))
+	}
+}
+
+// enum values can only be compared for equality with values of the same enum
+func (g *IRGenerator) checkEnumComparison(expr *ast.BinaryExpr) {
+	lhsType := g.typeOf(expr.Left)
+	rhsType := g.typeOf(expr.Right)
+	if expr.Operator.Type != scanner.EQUAL && expr.Operator.Type != scanner.NOT_EQUAL {
+		panic(fmt.Sprintf("operator '%s' is not supported on enum '%s' (line %d)", expr.Operator.Lexeme, lhsType.Token.Lexeme, expr.Operator.Line))
+	}
+	if lhsType.Token.Lexeme != rhsType.Token.Lexeme || lhsType.IsPointer != rhsType.IsPointer {
+		panic(fmt.Sprintf("cannot compare enum '%s' with '%s' (line %d)", lhsType.Token.Lexeme, rhsType.Token.Lexeme, expr.Operator.Line))
+	}
+}
+
+// and, or and ! only work on bools
+func checkBoolOperand(operator scanner.Token, operandType ast.Type) {
+	if !isPrimitive(operandType, "bool") {
+		panic(fmt.Sprintf("operator '%s' needs a 'bool' operand, got '%s' (line %d)", operator.Lexeme, typeName(operandType), operator.Line))
+	}
+}
+
+// the right operand is only evaluated when the left one doesn't already decide the result
+func (g *IRGenerator) VisitLogicalExpr(expr *ast.LogicalExpr) llvm.Value {
+	lhsVal := g.evaluate(expr.Left)
+	checkTypeParamOperator(expr.Operator, g.typeOf(expr.Left))
+	checkBoolOperand(expr.Operator, g.typeOf(expr.Left))
+	lhsBlock := g.builder.GetInsertBlock()
+	rhsBlock := llvm.AddBasicBlock(g.currentFunction, "logicalRhs")
+	mergeBlock := llvm.AddBasicBlock(g.currentFunction, "logicalMerge")
+
+	var shortCircuit llvm.Value
+	switch expr.Operator.Type {
+	case scanner.AND:
+		shortCircuit = llvm.ConstInt(g.ctx.Int1Type(), 0, false)
+		g.builder.CreateCondBr(lhsVal, rhsBlock, mergeBlock)
+	case scanner.OR:
+		shortCircuit = llvm.ConstInt(g.ctx.Int1Type(), 1, false)
+		g.builder.CreateCondBr(lhsVal, mergeBlock, rhsBlock)
+	default:
+		panic(fmt.Sprintf("can't handle operator '%s' in logical expression", expr.Operator.Lexeme))
+	}
+
+	// the right side only runs once the left side has been checked, so its nil checks carry over
+	g.builder.SetInsertPointAtEnd(rhsBlock)
+	var prevEnv *environment.Environment[variable]
+	if expr.Operator.Type == scanner.AND {
+		prevEnv = g.narrow(nonNilWhenTrue(expr.Left))
+	} else {
+		prevEnv = g.narrow(nonNilWhenFalse(expr.Left))
+	}
+	rhsVal := g.evaluate(expr.Right)
+	checkTypeParamOperator(expr.Operator, g.typeOf(expr.Right))
+	checkBoolOperand(expr.Operator, g.typeOf(expr.Right))
+	g.environment = prevEnv
+	// evaluating the right side may have moved us into a different block
+	rhsBlock = g.builder.GetInsertBlock()
+	g.builder.CreateBr(mergeBlock)
+
+	g.builder.SetInsertPointAtEnd(mergeBlock)
+	phi := g.builder.CreatePHI(g.ctx.Int1Type(), expr.Operator.Lexeme)
+	phi.AddIncoming([]llvm.Value{shortCircuit, rhsVal}, []llvm.BasicBlock{lhsBlock, rhsBlock})
+	g.exprTypes[expr] = primitiveType("bool")
+	return phi
+}
+
+// picks the instruction for the operand type: floats, signed and unsigned integers each have their own
+func (g *IRGenerator) binaryOp(operator scanner.Token, operandType ast.Type, lhsVal llvm.Value, rhsVal llvm.Value) llvm.Value {
+	if isString(operandType) {
+		return g.stringOp(operator, lhsVal, rhsVal)
+	}
+	isFloat := isFloatValue(lhsVal)
+	isUnsigned := isUnsigned(operandType)
+	switch operator.Type {
 	case scanner.PLUS:
-		return g.builder.CreateFAdd(lhsVal, rhsVal, "add")
+		if isFloat {
+			return g.builder.CreateFAdd(lhsVal, rhsVal, "add")
+		}
+		return g.builder.CreateAdd(lhsVal, rhsVal, "add")
 	case scanner.MINUS:
-		return g.builder.CreateFSub(lhsVal, rhsVal, "subtract")
+		if isFloat {
+			return g.builder.CreateFSub(lhsVal, rhsVal, "subtract")
+		}
+		return g.builder.CreateSub(lhsVal, rhsVal, "subtract")
 	case scanner.STAR:
-		return g.builder.CreateFMul(lhsVal, rhsVal, "multiply")
+		if isFloat {
+			return g.builder.CreateFMul(lhsVal, rhsVal, "multiply")
+		}
+		return g.builder.CreateMul(lhsVal, rhsVal, "multiply")
 	case scanner.SLASH:
-		return g.builder.CreateExactSDiv(lhsVal, rhsVal, "divide")
+		if isFloat {
+			return g.builder.CreateFDiv(lhsVal, rhsVal, "divide")
+		}
+		g.checkDivisor(operator, rhsVal)
+		if isUnsigned {
+			return g.builder.CreateUDiv(lhsVal, rhsVal, "divide")
+		}
+		return g.builder.CreateSDiv(lhsVal, g.signedDivisor(lhsVal, rhsVal), "divide")
+	case scanner.MOD:
+		// the result takes the sign of the left side, like C and Go
+		if isFloat {
+			return g.builder.CreateFRem(lhsVal, rhsVal, "remainder")
+		}
+		g.checkDivisor(operator, rhsVal)
+		if isUnsigned {
+			return g.builder.CreateURem(lhsVal, rhsVal, "remainder")
+		}
+		return g.builder.CreateSRem(lhsVal, g.signedDivisor(lhsVal, rhsVal), "remainder")
+	case scanner.ADDRESS:
+		return g.builder.CreateAnd(lhsVal, rhsVal, "and")
+	case scanner.PIPE:
+		return g.builder.CreateOr(lhsVal, rhsVal, "or")
+	case scanner.CARET:
+		return g.builder.CreateXor(lhsVal, rhsVal, "xor")
+	case scanner.SHIFT_LEFT:
+		g.checkShiftCount(operator, rhsVal)
+		return g.builder.CreateShl(lhsVal, rhsVal, "shiftLeft")
+	case scanner.SHIFT_RIGHT:
+		g.checkShiftCount(operator, rhsVal)
+		// unsigned values shift in zeros, signed ones copy the sign bit
+		if isUnsigned {
+			return g.builder.CreateLShr(lhsVal, rhsVal, "shiftRight")
+		}
+		return g.builder.CreateAShr(lhsVal, rhsVal, "shiftRight")
 	case scanner.LESS:
-		return g.builder.CreateFCmp(llvm.FloatOLT, lhsVal, rhsVal, "less than")
+		return g.compare(isFloat, isUnsigned, llvm.FloatOLT, llvm.IntULT, llvm.IntSLT, lhsVal, rhsVal, "less than")
 	case scanner.LESS_EQ:
-		return g.builder.CreateFCmp(llvm.FloatOLE, lhsVal, rhsVal, "less than or equal to")
+		return g.compare(isFloat, isUnsigned, llvm.FloatOLE, llvm.IntULE, llvm.IntSLE, lhsVal, rhsVal, "less than or equal to")
 	case scanner.GREATER:
-		return g.builder.CreateFCmp(llvm.FloatOGT, lhsVal, rhsVal, "greater than")
+		return g.compare(isFloat, isUnsigned, llvm.FloatOGT, llvm.IntUGT, llvm.IntSGT, lhsVal, rhsVal, "greater than")
 	case scanner.GREATER_EQ:
-		return g.builder.CreateFCmp(llvm.FloatOGE, lhsVal, rhsVal, "greater than or equal to")
+		return g.compare(isFloat, isUnsigned, llvm.FloatOGE, llvm.IntUGE, llvm.IntSGE, lhsVal, rhsVal, "greater than or equal to")
 	case scanner.EQUAL:
-		return g.builder.CreateFCmp(llvm.FloatUEQ, lhsVal, rhsVal, "equal")
+		return g.compare(isFloat, isUnsigned, llvm.FloatUEQ, llvm.IntEQ, llvm.IntEQ, lhsVal, rhsVal, "equal")
 	case scanner.NOT_EQUAL:
-		return g.builder.CreateFCmp(llvm.FloatUNE, lhsVal, rhsVal, "not equal")
+		return g.compare(isFloat, isUnsigned, llvm.FloatUNE, llvm.IntNE, llvm.IntNE, lhsVal, rhsVal, "not equal")
 	default:
-		panic(fmt.Sprintf("can't handle operator '%s' in binary expression", expr.Operator.Lexeme))
+		panic(fmt.Sprintf("can't handle operator '%s' in binary expression", operator.Lexeme))
 	}
 }
+
+// integer division by zero is undefined in llvm, so with CheckDivision it aborts instead
+func (g *IRGenerator) checkDivisor(operator scanner.Token, divisor llvm.Value) {
+	if !g.CheckDivision {
+		return
+	}
+	isNotZero := g.builder.CreateICmp(llvm.IntNE, divisor, llvm.ConstNull(divisor.Type()), "isNotZero")
+	g.trapUnless(isNotZero, fmt.Sprintf("division by zero (line %d)", operator.Line))
+}
+
+// the most negative value divided by -1 doesn't fit, llvm leaves that undefined and the cpu traps on it. like go,
+// the quotient wraps around to the dividend and the remainder is 0, which dividing by 1 instead gives
+func (g *IRGenerator) signedDivisor(dividend llvm.Value, divisor llvm.Value) llvm.Value {
+	intType := dividend.Type()
+	minValue := llvm.ConstInt(intType, 1<<(intType.IntTypeWidth()-1), false)
+	isMin := g.builder.CreateICmp(llvm.IntEQ, dividend, minValue, "")
+	isMinusOne := g.builder.CreateICmp(llvm.IntEQ, divisor, llvm.ConstAllOnes(intType), "")
+	overflows := g.builder.CreateAnd(isMin, isMinusOne, "overflows")
+	return g.builder.CreateSelect(overflows, llvm.ConstInt(intType, 1, false), divisor, "")
+}
+
+func (g *IRGenerator) compare(isFloat bool, isUnsigned bool, floatPredicate llvm.FloatPredicate, unsignedPredicate llvm.IntPredicate, signedPredicate llvm.IntPredicate, lhsVal llvm.Value, rhsVal llvm.Value, name string) llvm.Value {
+	if isFloat {
+		return g.builder.CreateFCmp(floatPredicate, lhsVal, rhsVal, name)
+	} else if isUnsigned {
+		return g.builder.CreateICmp(unsignedPredicate, lhsVal, rhsVal, name)
+	}
+	return g.builder.CreateICmp(signedPredicate, lhsVal, rhsVal, name)
+}
 func (g *IRGenerator) VisitUnaryExpr(expr *ast.UnaryExpr) llvm.Value {
 	switch expr.Operator.Type {
 	case scanner.MINUS:
+		// -128i8 is checked as a whole so the most negative value of a type can be written
+		if number, ok := expr.Right.(*ast.NumberExpr); ok && number.Suffix != nil {
+			g.exprTypes[expr] = *number.Suffix
+			return g.numericConstant(constant.UnaryOp(token.SUB, number.Value, 0), *number.Suffix, number.Suffix.Token.Line)
+		}
 		right := g.evaluate(expr.Right)
-		return g.builder.CreateFNeg(right, "negate")
-	case scanner.ADDRESS:
-		g.identifierAddress = true
+		checkTypeParamOperator(expr.Operator, g.typeOf(expr.Right))
+		g.exprTypes[expr] = g.typeOf(expr.Right)
+		if isFloatValue(right) {
+			return g.builder.CreateFNeg(right, "negate")
+		}
+		return g.builder.CreateNeg(right, "negate")
+	case scanner.BANG:
 		right := g.evaluate(expr.Right)
-		g.identifierAddress = false
+		checkTypeParamOperator(expr.Operator, g.typeOf(expr.Right))
+		checkBoolOperand(expr.Operator, g.typeOf(expr.Right))
+		g.exprTypes[expr] = primitiveType("bool")
+		return g.builder.CreateNot(right, "not")
+	case scanner.TILDE:
+		if literal, isLiteral := literalValue(expr); isLiteral {
+			g.exprTypes[expr] = foldedIntegerType(literal)
+			return g.numericConstant(literal, g.exprTypes[expr], expr.Operator.Line)
+		}
+		right := g.evaluate(expr.Right)
+		rightType := g.typeOf(expr.Right)
+		checkTypeParamOperator(expr.Operator, rightType)
+		if !isInteger(rightType) {
+			panic(fmt.Sprintf("operator '~' needs an integer operand, got '%s' (line %d)", rightType.Token.Lexeme, expr.Operator.Line))
+		}
+		g.exprTypes[expr] = rightType
+		return g.builder.CreateNot(right, "complement")
+	case scanner.ADDRESS:
+		right := g.evaluateAddress(expr.Right)
+		g.checkMutable(expr.Right, "take the address of")
+		rightType := g.typeOf(expr.Right)
+		// a type only records whether it's a pointer, so there's no way to describe a pointer to a pointer
+		if rightType.IsPointer {
+			panic(fmt.Sprintf("cannot take the address of '%s', pointers to pointers aren't supported (line %d)", typeName(rightType), expr.Operator.Line))
+		}
+		rightType.IsPointer = true
+		g.exprTypes[expr] = rightType
 		return right
 	case scanner.STAR:
-		fmt.Println("here")
-		right := g.evaluate(expr.Right)
-		return g.builder.CreateLoad(right.Type(), right, "dereference")
+		right := g.evaluateAddress(expr)
+		return g.builder.CreateLoad(g.llvmTypeFromAstType(g.typeOf(expr)), right, "dereference")
 	default:
 		panic(fmt.Sprintf("unhandled unary operator '%s'", expr.Operator.Lexeme))
 	}
@@ -297,28 +1316,560 @@ func (g *IRGenerator) evaluate(expr ast.Expr) llvm.Value {
 	return expr.Visit(g)
 }
 
+// the language type of an expression that has already been evaluated
+func (g *IRGenerator) typeOf(expr ast.Expr) ast.Type {
+	return g.exprTypes[expr]
+}
+
+// evaluates an assignable expression to a pointer to its storage instead of its value
+func (g *IRGenerator) evaluateAddress(expr ast.Expr) llvm.Value {
+	switch target := expr.(type) {
+	case *ast.IdentifierExpr:
+		g.identifierAddress = true
+		ptr := g.evaluate(target)
+		g.identifierAddress = false
+		return ptr
+	case *ast.GroupingExpr:
+		ptr := g.evaluateAddress(target.Expression)
+		g.exprTypes[target] = g.typeOf(target.Expression)
+		return ptr
+	case *ast.GetExpr:
+		if _, enumName, ok := g.enumAccess(target); ok {
+			panic(fmt.Sprintf("cannot assign to enum variant '%s.%s' (line %d)", enumName.Lexeme, target.Name.Lexeme, target.Name.Line))
+		}
+		structPtr, structType := g.evaluateAggregateAddress(target.Object, target.Name.Line)
+		if structType.IsTuple {
+			return g.tupleElementAddress(target, structPtr, structType)
+		}
+		if !g.isStruct(structType) {
+			panic(fmt.Sprintf("cannot get field '%s' of a value of type '%s' since it isn't a struct (line %d)", target.Name.Lexeme, typeName(structType), target.Name.Line))
+		}
+		info := g.lookupStruct(structType.Token)
+		index, exists := info.fieldIndex(target.Name.Lexeme)
+		if !exists {
+			panic(fmt.Sprintf("struct '%s' has no field '%s' (line %d)", structType.Token.Lexeme, target.Name.Lexeme, target.Name.Line))
+		}
+		g.checkVisibility(structType.Token.Lexeme, target.Name, info.fields[index].IsPublic)
+		g.exprTypes[target] = info.fields[index].Type
+		if info.generic != "" && structType.Token.Lexeme == g.currentStruct {
+			// a generic struct's own methods see the fields declared with its type params as values of them
+			g.exprTypes[target] = g.resolveType(g.genericStructs[info.generic].Fields[index].Type)
+		}
+		return g.builder.CreateStructGEP(info.llvmType, structPtr, index, target.Name.Lexeme+"Ptr")
+	case *ast.IndexExpr:
+		arrayPtr, arrayType := g.evaluateAggregateAddress(target.Object, target.Bracket.Line)
+		if arrayType.IsSlice {
+			return g.sliceElementAddress(target, arrayPtr, arrayType)
+		}
+		if isString(arrayType) {
+			return g.stringElementAddress(target, arrayPtr)
+		}
+		if !arrayType.IsArray {
+			panic(fmt.Sprintf("cannot index into non-array value (line %d)", target.Bracket.Line))
+		}
+		index := g.evaluateIndex(target.Index, target.Bracket)
+		// indices past the end of the array abort instead of reading or writing random memory
+		inBounds := g.builder.CreateICmp(llvm.IntULT, index, llvm.ConstInt(g.ctx.Int64Type(), uint64(arrayType.Length), false), "inBounds")
+		g.trapUnless(inBounds, fmt.Sprintf("index out of bounds (line %d)", target.Bracket.Line))
+		g.exprTypes[target] = *arrayType.Elem
+		zero := llvm.ConstInt(g.ctx.Int64Type(), 0, false)
+		return g.builder.CreateInBoundsGEP(g.llvmTypeFromAstType(arrayType), arrayPtr, []llvm.Value{zero, index}, "elementPtr")
+	case *ast.UnaryExpr:
+		if target.Operator.Type == scanner.STAR {
+			// the pointer itself is the address of what it points to
+			ptr := g.evaluate(target.Right)
+			pointeeType := g.typeOf(target.Right)
+			if !pointeeType.IsPointer {
+				panic(fmt.Sprintf("cannot dereference a value of type '%s' since it isn't a pointer (line %d)", typeName(pointeeType), target.Operator.Line))
+			}
+			checkDereference(pointeeType, target.Operator.Line)
+			pointeeType.IsPointer = false
+			g.exprTypes[target] = pointeeType
+			return ptr
+		}
+	}
+	panic("expression is not assignable")
+}
+
+// checks that expr's value can be stored somewhere of the target type. nil becomes a null pointer of that type
+// and number literals become constants of that type
+func (g *IRGenerator) coerce(expr ast.Expr, value llvm.Value, target ast.Type, line int) llvm.Value {
+	valueType := g.typeOf(expr)
+	if isNil(valueType) {
+		if !target.IsPointer || !target.IsNullable {
+			panic(fmt.Sprintf("cannot use nil as a value of a non-nullable type (line %d)", line))
+		}
+		return llvm.ConstPointerNull(g.llvmTypeFromAstType(target))
+	}
+	if valueType.IsErrorUnion && !target.IsErrorUnion {
+		panic(fmt.Sprintf("cannot use a value of type '%s' as '%s' since it may be an error, handle it with try or catch first (line %d)", typeName(valueType), typeName(target), line))
+	}
+	if valueType.IsNullable && !target.IsNullable && target.IsPointer {
+		panic(fmt.Sprintf("cannot use a nullable pointer where a non-nullable one is expected, check it against nil or unwrap it with '.?' (line %d)", line))
+	}
+	if literal, ok := literalValue(expr); ok && isNumeric(target) {
+		g.exprTypes[expr] = target
+		return g.numericConstant(literal, target, line)
+	}
+	if arrayLiteral, ok := expr.(*ast.ArrayLiteralExpr); ok && target.IsArray && !target.IsPointer {
+		checkArrayLength(arrayLiteral, target, line)
+		arrayVal := llvm.ConstNull(g.llvmTypeFromAstType(target))
+		for i, element := range arrayLiteral.Elements {
+			elementVal := g.coerce(element, g.builder.CreateExtractValue(value, i, ""), *target.Elem, line)
+			arrayVal = g.builder.CreateInsertValue(arrayVal, elementVal, i, "")
+		}
+		g.exprTypes[expr] = target
+		return arrayVal
+	}
+	if tuple, ok := expr.(*ast.TupleExpr); ok && target.IsTuple && !target.IsPointer && len(target.Elems) == len(tuple.Elements) {
+		return g.tupleLiteral(tuple, value, target, line)
+	}
+	if (valueType.IsTuple || target.IsTuple) && typeName(valueType) != typeName(target) {
+		panic(fmt.Sprintf("cannot use a value of type '%s' as '%s' (line %d)", typeName(valueType), typeName(target), line))
+	}
+	if (valueType.IsFunction || target.IsFunction) && typeName(valueType) != typeName(target) {
+		panic(fmt.Sprintf("cannot use a value of type '%s' as '%s' (line %d)", typeName(valueType), typeName(target), line))
+	}
+	if g.isInterface(target) && !target.IsPointer {
+		return g.interfaceValue(value, valueType, target, line)
+	}
+	if g.isStruct(valueType) && g.isStruct(target) && (valueType.Token.Lexeme != target.Token.Lexeme || valueType.IsPointer != target.IsPointer) {
+		// instances of the same generic struct only differ by name, like Pair[i32] and Pair[number]
+		panic(fmt.Sprintf("cannot use a value of type '%s' as '%s' (line %d)", typeName(valueType), typeName(target), line))
+	}
+	if isNumeric(valueType) && isNumeric(target) && !sameNumericType(valueType, target) {
+		panic(fmt.Sprintf("cannot use a value of type '%s' as '%s' without a cast (line %d)", numericName(valueType), numericName(target), line))
+	}
+	if !isNumeric(valueType) || !isNumeric(target) {
+		// a pointer that is never nil can go wherever a nullable one is expected
+		if target.IsNullable && target.IsPointer && valueType.IsPointer {
+			valueType.IsNullable = true
+		}
+		if typeName(valueType) != typeName(target) {
+			panic(fmt.Sprintf("cannot use a value of type '%s' as '%s' (line %d)", typeName(valueType), typeName(target), line))
+		}
+	}
+	return value
+}
+
+// rejects writes that would modify a const, including through its fields and array elements.
+// pointers and slices are not followed since the memory they refer to doesn't belong to the const
+func (g *IRGenerator) checkMutable(expr ast.Expr, action string) {
+	switch target := expr.(type) {
+	case *ast.IdentifierExpr:
+		if variable, exists := g.environment.Get(target.Value.Lexeme); exists && variable.isConst {
+			panic(fmt.Sprintf("cannot %s const '%s' (line %d)", action, target.Value.Lexeme, target.Value.Line))
+		}
+	case *ast.GroupingExpr:
+		g.checkMutable(target.Expression, action)
+	case *ast.GetExpr:
+		if !g.typeOf(target.Object).IsPointer {
+			g.checkMutable(target.Object, action)
+		}
+	case *ast.IndexExpr:
+		objectType := g.typeOf(target.Object)
+		if isString(objectType) {
+			panic(fmt.Sprintf("cannot %s an element of a string, strings are immutable (line %d)", action, target.Bracket.Line))
+		}
+		if objectType.IsArray && !objectType.IsPointer {
+			g.checkMutable(target.Object, action)
+		}
+	}
+}
+
+// whether a method can change the struct it's called on, by assigning to it, taking its address, calling
+// a method that changes it or handing self to someone else
+func (g *IRGenerator) modifiesReceiver(receiverType ast.Type, method *methodInfo) bool {
+	if method.modifies != nil {
+		return *method.modifies
+	}
+	modifies := false
+	// a method that calls itself doesn't change anything by doing so
+	method.modifies = &modifies
+	var visit func(node any) bool
+	// reading part of the receiver doesn't change it, only the indices in self.items[i] can
+	visitIndices := func(part ast.Expr) {
+		for part != nil {
+			switch expr := part.(type) {
+			case *ast.GetExpr:
+				part = expr.Object
+			case *ast.IndexExpr:
+				ast.Inspect(expr.Index, visit)
+				part = expr.Object
+			case *ast.GroupingExpr:
+				part = expr.Expression
+			default:
+				part = nil
+			}
+		}
+	}
+	visit = func(node any) bool {
+		switch node := node.(type) {
+		case *ast.AssignExpr:
+			if _, isPart := g.receiverPart(receiverType, node.Target); isPart {
+				modifies = true
+			}
+		case *ast.IncrementExpr:
+			if _, isPart := g.receiverPart(receiverType, node.Target); isPart {
+				modifies = true
+			}
+		case *ast.UnaryExpr:
+			if _, isPart := g.receiverPart(receiverType, node.Right); isPart && node.Operator.Type == scanner.ADDRESS {
+				modifies = true
+			}
+			if identifier, ok := node.Right.(*ast.IdentifierExpr); ok && identifier.Value.Lexeme == "self" && node.Operator.Type == scanner.STAR {
+				// *self only copies the receiver
+				return false
+			}
+		case *ast.CallExpr:
+			get, isGet := node.Callee.(*ast.GetExpr)
+			if !isGet {
+				break
+			}
+			objectType, isPart := g.receiverPart(receiverType, get.Object)
+			if !isPart {
+				break
+			}
+			if info, exists := g.structs[objectType.Token.Lexeme]; exists && !objectType.IsPointer {
+				if called, exists := info.methods[get.Name.Lexeme]; exists && g.modifiesReceiver(objectType, called) {
+					modifies = true
+				}
+			}
+			visitIndices(get.Object)
+			for _, arg := range node.Args {
+				ast.Inspect(arg, visit)
+			}
+			return false
+		case *ast.GetExpr, *ast.IndexExpr:
+			if _, isPart := g.receiverPart(receiverType, node.(ast.Expr)); isPart {
+				visitIndices(node.(ast.Expr))
+				return false
+			}
+		case *ast.IdentifierExpr:
+			// self used on its own could end up anywhere
+			if node.Value.Lexeme == "self" {
+				modifies = true
+			}
+		}
+		return !modifies
+	}
+	ast.Inspect(method.stmt.Body, visit)
+	return modifies
+}
+
+// the type of expr when it names the receiver of a method or part of it, like self.pos.x or self.items[0],
+// rather than something it points to
+func (g *IRGenerator) receiverPart(receiverType ast.Type, expr ast.Expr) (ast.Type, bool) {
+	switch expr := expr.(type) {
+	case *ast.IdentifierExpr:
+		return receiverType, expr.Value.Lexeme == "self"
+	case *ast.GroupingExpr:
+		return g.receiverPart(receiverType, expr.Expression)
+	case *ast.GetExpr:
+		objectType, isPart := g.receiverPart(receiverType, expr.Object)
+		info, exists := g.structs[objectType.Token.Lexeme]
+		if !isPart || !exists || objectType.IsPointer {
+			return ast.Type{}, false
+		}
+		index, isField := info.fieldIndex(expr.Name.Lexeme)
+		if !isField {
+			return ast.Type{}, false
+		}
+		return info.fields[index].Type, true
+	case *ast.IndexExpr:
+		objectType, isPart := g.receiverPart(receiverType, expr.Object)
+		if !isPart || !objectType.IsArray || objectType.IsPointer {
+			return ast.Type{}, false
+		}
+		return *objectType.Elem, true
+	}
+	return ast.Type{}, false
+}
+
+// evaluates the left side of a field access or index to a pointer to the struct or array, following a pointer to one if needed
+func (g *IRGenerator) evaluateAggregateAddress(expr ast.Expr, line int) (llvm.Value, ast.Type) {
+	var aggregatePtr llvm.Value
+	var aggregateType ast.Type
+	if isAddressable(expr) {
+		aggregatePtr = g.evaluateAddress(expr)
+		aggregateType = g.typeOf(expr)
+		if aggregateType.IsPointer {
+			aggregatePtr = g.builder.CreateLoad(g.llvmTypeFromAstType(aggregateType), aggregatePtr, "")
+		}
+	} else {
+		aggregateVal := g.evaluate(expr)
+		aggregateType = g.typeOf(expr)
+		aggregatePtr = aggregateVal
+		if !aggregateType.IsPointer {
+			// temporary values like call results get spilled to the stack so they have an address
+			aggregatePtr = g.createEntryAlloca(aggregateVal.Type(), "")
+			g.builder.CreateStore(aggregateVal, aggregatePtr)
+		}
+	}
+	if aggregateType.IsPointer {
+		checkDereference(aggregateType, line)
+	}
+	aggregateType.IsPointer = false
+	return aggregatePtr, aggregateType
+}
+
+// evaluates an index and widens it to the i64 that GEPs expect
+func (g *IRGenerator) evaluateIndex(expr ast.Expr, bracket scanner.Token) llvm.Value {
+	index := g.evaluate(expr)
+	indexType := g.typeOf(expr)
+	if literal, isLiteral := literalValue(expr); isLiteral {
+		return g.numericConstant(literal, primitiveType("i64"), bracket.Line)
+	}
+	switch {
+	case !isNumeric(indexType):
+		panic(fmt.Sprintf("index must be a number (line %d)", bracket.Line))
+	case isFloat(indexType):
+		return g.builder.CreateFPToSI(index, g.ctx.Int64Type(), "index")
+	case index.Type().IntTypeWidth() == 64:
+		return index
+	case isUnsigned(indexType):
+		return g.builder.CreateZExt(index, g.ctx.Int64Type(), "index")
+	default:
+		return g.builder.CreateSExt(index, g.ctx.Int64Type(), "index")
+	}
+}
+
+// aborts the program with a runtime error when ok is false
+func (g *IRGenerator) trapUnless(ok llvm.Value, message string) {
+	okBlock := llvm.AddBasicBlock(g.currentFunction, "ok")
+	trapBlock := llvm.AddBasicBlock(g.currentFunction, "trap")
+	g.builder.CreateCondBr(ok, okBlock, trapBlock)
+
+	g.builder.SetInsertPointAtEnd(trapBlock)
+	printf := g.module.NamedFunction("printf")
+	format := g.builder.CreateGlobalStringPtr("runtime error: "+message+"\n", "")
+	g.builder.CreateCall(printf.GlobalValueType(), printf, []llvm.Value{format}, "")
+	// make sure everything printed so far shows up before the program dies
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	fflush := g.runtimeFunction("fflush", llvm.FunctionType(g.ctx.Int32Type(), []llvm.Type{bytePtr}, false))
+	g.builder.CreateCall(fflush.GlobalValueType(), fflush, []llvm.Value{llvm.ConstNull(bytePtr)}, "")
+	trap := g.runtimeFunction("llvm.trap", llvm.FunctionType(g.ctx.VoidType(), nil, false))
+	g.builder.CreateCall(trap.GlobalValueType(), trap, nil, "")
+	g.builder.CreateUnreachable()
+
+	g.builder.SetInsertPointAtEnd(okBlock)
+}
+
+// looks up a function the generated code depends on, declaring it the first time it's needed
+func (g *IRGenerator) runtimeFunction(name string, fnType llvm.Type) llvm.Value {
+	fn := g.module.NamedFunction(name)
+	if fn.IsNil() {
+		fn = llvm.AddFunction(g.module, name, fnType)
+	}
+	return fn
+}
+
+// evaluates expr as a value of the target type. array literals take their element type from the target instead of
+// from their first element, and are checked against its length
+func (g *IRGenerator) evaluateAs(expr ast.Expr, target ast.Type, line int) llvm.Value {
+	// generics don't have to fall back on the types of literals either, let p Pair[i32] = Pair{first: 1, ...}
+	switch expr := expr.(type) {
+	case *ast.StructLiteralExpr:
+		if instance, exists := g.structs[target.Token.Lexeme]; exists && len(expr.TypeArgs) == 0 && instance.generic == expr.Name.Lexeme && !target.IsPointer {
+			return g.structLiteral(expr, target.Token.Lexeme)
+		}
+	case *ast.CallExpr:
+		if identifier, isIdentifier := expr.Callee.(*ast.IdentifierExpr); isIdentifier {
+			if callee, exists := g.environment.Get(identifier.Value.Lexeme); exists && callee.generic != nil {
+				return g.coerce(expr, g.genericCall(expr, identifier.Value, callee.generic, &target), target, line)
+			}
+		}
+	}
+	arrayLiteral, ok := expr.(*ast.ArrayLiteralExpr)
+	if !ok || !target.IsArray || target.IsPointer {
+		return g.coerce(expr, g.evaluate(expr), target, line)
+	}
+	checkArrayLength(arrayLiteral, target, line)
+	arrayVal := llvm.ConstNull(g.llvmTypeFromAstType(target))
+	for i, element := range arrayLiteral.Elements {
+		arrayVal = g.builder.CreateInsertValue(arrayVal, g.evaluateAs(element, *target.Elem, line), i, "")
+	}
+	g.exprTypes[expr] = target
+	return arrayVal
+}
+
+func checkArrayLength(expr *ast.ArrayLiteralExpr, target ast.Type, line int) {
+	if len(expr.Elements) != target.Length {
+		panic(fmt.Sprintf("array literal has %d elements but '%s' holds %d (line %d)", len(expr.Elements), typeName(target), target.Length, line))
+	}
+}
+
+// expressions evaluateAddress knows how to produce a pointer for
+func isAddressable(expr ast.Expr) bool {
+	switch target := expr.(type) {
+	case *ast.IdentifierExpr, *ast.GetExpr, *ast.IndexExpr:
+		return true
+	case *ast.GroupingExpr:
+		return isAddressable(target.Expression)
+	case *ast.UnaryExpr:
+		return target.Operator.Type == scanner.STAR
+	default:
+		return false
+	}
+}
+
+// reports whether a field access is actually a qualified enum variant like Color.RED
+func (g *IRGenerator) enumAccess(expr *ast.GetExpr) (*enumInfo, scanner.Token, bool) {
+	identifier, ok := expr.Object.(*ast.IdentifierExpr)
+	if !ok {
+		return nil, scanner.Token{}, false
+	}
+	enum, exists := g.enums[identifier.Value.Lexeme]
+	return enum, identifier.Value, exists
+}
+
+func (g *IRGenerator) isStruct(type_ ast.Type) bool {
+	_, exists := g.structs[type_.Token.Lexeme]
+	return exists && !type_.IsArray && !type_.IsSlice && !type_.IsErrorUnion && !type_.IsFunction
+}
+
+// members without 'pub' can only be used from inside of the struct's own methods
+func (g *IRGenerator) checkVisibility(structName string, member scanner.Token, isPublic bool) {
+	if !isPublic && g.currentStruct != structName {
+		panic(fmt.Sprintf("'%s' is a private member of struct '%s' (line %d)", member.Lexeme, structName, member.Line))
+	}
+}
+
+func (g *IRGenerator) lookupStruct(name scanner.Token) *structInfo {
+	info, exists := g.structs[name.Lexeme]
+	if !exists {
+		panic(fmt.Sprintf("unknown struct type '%s' (line %d)", name.Lexeme, name.Line))
+	}
+	return info
+}
+
+// reports whether the block currently being built already ends in a terminator (ret/br)
+func (g *IRGenerator) isTerminated() bool {
+	last := g.builder.GetInsertBlock().LastInstruction()
+	if last.IsNil() {
+		return false
+	}
+	switch last.InstructionOpcode() {
+	case llvm.Ret, llvm.Br, llvm.Switch, llvm.Unreachable:
+		return true
+	}
+	return false
+}
+
+// allocas are hoisted into the entry block so locals declared inside of loops don't grow the stack every iteration
+func (g *IRGenerator) createEntryAlloca(type_ llvm.Type, name string) llvm.Value {
+	currentBlock := g.builder.GetInsertBlock()
+	entry := g.currentFunction.EntryBasicBlock()
+	first := entry.FirstInstruction()
+	if first.IsNil() {
+		g.builder.SetInsertPointAtEnd(entry)
+	} else {
+		g.builder.SetInsertPointBefore(first)
+	}
+	alloca := g.builder.CreateAlloca(type_, name)
+	g.builder.SetInsertPointAtEnd(currentBlock)
+	return alloca
+}
+
+// branches to the given block unless control flow already left the current block
+func (g *IRGenerator) branchTo(block llvm.BasicBlock) {
+	if !g.isTerminated() {
+		g.builder.CreateBr(block)
+	}
+}
+
+// how a type is written in source, for error messages
+func typeName(type_ ast.Type) string {
+	name := type_.Token.Lexeme
+	switch {
+	case type_.IsArray:
+		name = fmt.Sprintf("[%d]%s", type_.Length, typeName(*type_.Elem))
+	case type_.IsSlice:
+		name = "[]" + typeName(*type_.Elem)
+	case type_.IsErrorUnion:
+		name = typeName(*type_.Elem) + "?"
+	case type_.IsFunction:
+		params := make([]string, 0)
+		for _, param := range type_.Params {
+			params = append(params, typeName(param))
+		}
+		name = "fn(" + strings.Join(params, ", ") + ")"
+		if type_.Return.Token.Lexeme != "void" {
+			name += " " + typeName(*type_.Return)
+		}
+	case type_.IsTuple:
+		elems := make([]string, 0)
+		for _, elem := range type_.Elems {
+			elems = append(elems, typeName(elem))
+		}
+		name = "(" + strings.Join(elems, ", ") + ")"
+	}
+	if type_.IsPointer {
+		name = "*" + name
+	}
+	if type_.IsNullable && !isNil(type_) {
+		name = "?" + name
+	}
+	return name
+}
+
+// whether a type is the named primitive itself rather than something built from it, like a pointer or an array
+func isPrimitive(type_ ast.Type, name string) bool {
+	return type_.Token.Lexeme == name && !type_.IsPointer && !type_.IsArray && !type_.IsSlice && !type_.IsErrorUnion && !type_.IsFunction && !type_.IsTuple
+}
+
+func primitiveType(name string) ast.Type {
+	return ast.Type{Token: scanner.Token{Type: scanner.TYPE, Lexeme: name}}
+}
+
 func (g *IRGenerator) llvmTypeFromAstType(langType ast.Type) llvm.Type {
 	// assume it's always a TYPE token
+	langType = g.resolveType(langType)
 	var llvmType llvm.Type
-	if langType.Token.IsPrimitiveType() {
+	if isNil(langType) {
+		return llvm.PointerType(g.ctx.Int8Type(), 0)
+	}
+	if langType.IsArray {
+		llvmType = llvm.ArrayType(g.llvmTypeFromAstType(*langType.Elem), langType.Length)
+	} else if langType.IsSlice {
+		llvmType = g.sliceType(*langType.Elem)
+	} else if langType.IsErrorUnion {
+		llvmType = g.errorUnionType(*langType.Elem)
+	} else if langType.IsFunction {
+		llvmType = g.closureType()
+	} else if langType.IsTuple {
+		llvmType = g.tupleType(langType)
+	} else if langType.Token.IsPrimitiveType() {
 		switch langType.Token.Lexeme {
-		case "number":
+		case "number", "f64":
 			llvmType = g.ctx.DoubleType()
+		case "f32":
+			llvmType = g.ctx.FloatType()
+		case "i8", "u8":
+			llvmType = g.ctx.Int8Type()
+		case "i16", "u16":
+			llvmType = g.ctx.Int16Type()
+		case "i32", "u32":
+			llvmType = g.ctx.Int32Type()
+		case "i64", "u64":
+			llvmType = g.ctx.Int64Type()
 		case "string":
-			llvmType = llvm.PointerType(g.ctx.Int8Type(), 0)
+			llvmType = g.stringType()
 		case "bool":
 			llvmType = g.ctx.Int1Type()
 		case "char":
-			fmt.Println("here1")
 			llvmType = g.ctx.Int8Type()
 		case "void":
 			llvmType = g.ctx.VoidType()
 		}
+	} else if _, isEnum := g.enums[langType.Token.Lexeme]; isEnum {
+		llvmType = g.ctx.Int32Type()
+	} else if g.isInterface(langType) {
+		llvmType = g.interfaceType()
 	} else {
-		// TODO handle lookups of custom types
+		llvmType = g.lookupStruct(langType.Token).llvmType
 	}
 	if langType.IsPointer {
-		fmt.Println("here2")
 		llvmType = llvm.PointerType(llvmType, 0)
 	}
 
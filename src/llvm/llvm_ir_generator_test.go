@@ -0,0 +1,163 @@
+package llvm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus1400/kel/src/parser"
+	"github.com/prometheus1400/kel/src/scanner"
+)
+
+// every program in testdata is compiled and, when lli is installed, run. comments at the top of a program say
+// what should happen to it:
+//
+//	// expect: a line the program prints, in order
+//	// error: part of the error it fails to compile with
+//	// runtime error: part of the message it stops with at runtime
+//
+// main doesn't have to return an int, so the exit status of a program is ignored. one that is expected to run
+// must not be killed by a signal or write anything to stderr though, which is how lli reports invalid ir
+
+type expectations struct {
+	output       []string
+	compileError string
+	runtimeError string
+}
+
+func readExpectations(source []byte) expectations {
+	var want expectations
+	for _, line := range strings.Split(string(source), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "//"))
+		switch {
+		case strings.HasPrefix(line, "expect:"):
+			want.output = append(want.output, strings.TrimPrefix(strings.TrimPrefix(line, "expect:"), " "))
+		case strings.HasPrefix(line, "error:"):
+			want.compileError = strings.TrimSpace(strings.TrimPrefix(line, "error:"))
+		case strings.HasPrefix(line, "runtime error:"):
+			want.runtimeError = strings.TrimSpace(strings.TrimPrefix(line, "runtime error:"))
+		}
+	}
+	return want
+}
+
+// compiles the program to build/name.ll in the working directory, the same way the kel command does
+func compile(source []byte, name string) (err error) {
+	// the generator reports errors by panicking, anything else that panics is reported the same way
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	s := scanner.NewScanner()
+	s.Scan(source)
+	if s.HadError {
+		return errors.Join(s.Errors...)
+	}
+	p := parser.NewParser()
+	stmts := p.Parse(s.Tokens)
+	if p.HadError {
+		return errors.Join(p.Errors...)
+	}
+	gen := NewIRGenerator()
+	gen.CheckDivision = true
+	gen.GenerateIR(stmts, name)
+	return nil
+}
+
+// llvm 14 still defaults to typed pointers, the generated ir uses opaque ones
+func lliArgs(lli string) []string {
+	version, err := exec.Command(lli, "--version").Output()
+	if err != nil {
+		return nil
+	}
+	match := regexp.MustCompile(`LLVM version (\d+)`).FindSubmatch(version)
+	if match == nil {
+		return nil
+	}
+	if major, err := strconv.Atoi(string(match[1])); err == nil && major < 15 {
+		return []string{"-opaque-pointers"}
+	}
+	return nil
+}
+
+func TestPrograms(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.kel"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no programs in testdata: %v", err)
+	}
+	lli, lliErr := exec.LookPath("lli")
+	var args []string
+	if lliErr == nil {
+		args = lliArgs(lli)
+	}
+
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".kel")
+		t.Run(name, func(t *testing.T) {
+			source, err := os.ReadFile(filepath.Join(wd, path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := readExpectations(source)
+			err = compile(source, name)
+			if want.compileError != "" {
+				if err == nil {
+					t.Fatalf("compiled, want error containing %q", want.compileError)
+				}
+				if !strings.Contains(err.Error(), want.compileError) {
+					t.Fatalf("got error %q, want one containing %q", err, want.compileError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to compile: %v", err)
+			}
+			if lliErr != nil {
+				t.Skip("lli isn't installed, only checked that it compiles")
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd := exec.Command(lli, append(args, filepath.Join("build", name+".ll"))...)
+			cmd.Stdout, cmd.Stderr = &stdout, &stderr
+			err = cmd.Run()
+			if want.runtimeError != "" {
+				if err == nil {
+					t.Fatalf("ran to completion, want runtime error containing %q\n%s", want.runtimeError, stdout.String())
+				}
+				if !strings.Contains(stdout.String(), "runtime error: "+want.runtimeError) {
+					t.Fatalf("got output %q, want runtime error containing %q", stdout.String(), want.runtimeError)
+				}
+				return
+			}
+			var exitErr *exec.ExitError
+			if err != nil && (!errors.As(err, &exitErr) || !exitErr.Exited()) || stderr.Len() > 0 {
+				t.Fatalf("failed to run: %v\n%s%s", err, stdout.String(), stderr.String())
+			}
+			if got := strings.TrimSuffix(stdout.String(), "\n"); got != strings.Join(want.output, "\n") {
+				t.Fatalf("got output\n%s\nwant\n%s", got, strings.Join(want.output, "\n"))
+			}
+		})
+	}
+}
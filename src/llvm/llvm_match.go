@@ -0,0 +1,241 @@
+package llvm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// a pattern of a match arm with the constants it compares the subject against
+type matchCase struct {
+	arm   int
+	line  int
+	value llvm.Value // the constant, or the start of a range
+	end   llvm.Value // the end of a range, which it doesn't include
+}
+
+func (c matchCase) isRange() bool {
+	return !c.end.IsNil()
+}
+
+func (g *IRGenerator) VisitMatchStmt(stmt *ast.MatchStmt) {
+	armBlocks, mergeBlock := g.matchDispatch(stmt.Keyword, stmt.Subject, stmt.Arms, false)
+	for i, arm := range stmt.Arms {
+		g.builder.SetInsertPointAtEnd(armBlocks[i])
+		g.execute(arm.Body)
+		g.branchTo(mergeBlock)
+	}
+	g.builder.SetInsertPointAtEnd(mergeBlock)
+}
+
+func (g *IRGenerator) VisitMatchExpr(expr *ast.MatchExpr) llvm.Value {
+	armBlocks, mergeBlock := g.matchDispatch(expr.Keyword, expr.Subject, expr.Arms, true)
+	values := make([]llvm.Value, 0)
+	for i, arm := range expr.Arms {
+		g.builder.SetInsertPointAtEnd(armBlocks[i])
+		values = append(values, g.evaluate(arm.Value))
+		// evaluating the value may have moved us into a different block
+		armBlocks[i] = g.builder.GetInsertBlock()
+	}
+
+	// like array literals, the result takes the type of the first arm that has one of its own
+	resultType := g.typeOf(expr.Arms[0].Value)
+	for _, arm := range expr.Arms {
+		if _, isLiteral := literalValue(arm.Value); !isLiteral && !isNil(g.typeOf(arm.Value)) {
+			resultType = g.typeOf(arm.Value)
+			break
+		}
+	}
+	llvmType := g.llvmTypeFromAstType(resultType)
+	if llvmType.TypeKind() == llvm.VoidTypeKind || isNil(resultType) {
+		panic(fmt.Sprintf("the arms of a match expression must have a type (line %d)", expr.Keyword.Line))
+	}
+	for i, arm := range expr.Arms {
+		g.builder.SetInsertPointAtEnd(armBlocks[i])
+		values[i] = g.coerce(arm.Value, values[i], resultType, expr.Keyword.Line)
+		if values[i].Type() != llvmType {
+			panic(fmt.Sprintf("match arms must all have the same type, got '%s' and '%s' (line %d)", typeName(resultType), typeName(g.typeOf(arm.Value)), expr.Keyword.Line))
+		}
+		g.builder.CreateBr(mergeBlock)
+	}
+
+	g.builder.SetInsertPointAtEnd(mergeBlock)
+	phi := g.builder.CreatePHI(llvmType, "match")
+	phi.AddIncoming(values, armBlocks)
+	g.exprTypes[expr] = resultType
+	return phi
+}
+
+// evaluates the subject and branches to the block of the first arm it matches, returning the arm blocks and the
+// block after the match. integer like subjects go through a switch, anything else is compared arm by arm
+func (g *IRGenerator) matchDispatch(keyword scanner.Token, subject ast.Expr, arms []ast.MatchArm, isExpression bool) ([]llvm.BasicBlock, llvm.BasicBlock) {
+	subjectVal := g.evaluate(subject)
+	subjectType := g.typeOf(subject)
+	_, isEnum := g.enums[subjectType.Token.Lexeme]
+	isBool := subjectType.Token.Lexeme == "bool"
+	isChar := subjectType.Token.Lexeme == "char"
+	if subjectType.IsPointer || subjectType.IsArray || subjectType.IsSlice || subjectType.IsErrorUnion || subjectType.IsFunction ||
+		!(isNumeric(subjectType) || isString(subjectType) || isEnum || isBool || isChar) {
+		panic(fmt.Sprintf("cannot match on a value of type '%s' (line %d)", typeName(subjectType), keyword.Line))
+	}
+
+	cases := make([]matchCase, 0)
+	wildcard := -1
+	for i, arm := range arms {
+		if wildcard >= 0 {
+			panic(fmt.Sprintf("match arm can never run since the '_' before it matches everything (line %d)", arm.Patterns[0].Token.Line))
+		}
+		for _, pattern := range arm.Patterns {
+			if pattern.IsWildcard {
+				wildcard = i
+				continue
+			}
+			matchCase := matchCase{arm: i, line: pattern.Token.Line, value: g.patternValue(pattern.Value, subjectType, pattern.Token.Line)}
+			if pattern.End != nil {
+				if !isNumeric(subjectType) && !isChar {
+					panic(fmt.Sprintf("range patterns only work on numbers and chars, not '%s' (line %d)", typeName(subjectType), pattern.Token.Line))
+				}
+				matchCase.end = g.patternValue(pattern.End, subjectType, pattern.Token.Line)
+			}
+			cases = append(cases, matchCase)
+		}
+	}
+	isSwitch := !isFloat(subjectType) && !isString(subjectType)
+	if isSwitch {
+		checkReachable(cases, isUnsigned(subjectType))
+	}
+
+	dispatchBlock := g.builder.GetInsertBlock()
+	armBlocks := make([]llvm.BasicBlock, 0)
+	for i := range arms {
+		armBlocks = append(armBlocks, llvm.AddBasicBlock(g.currentFunction, fmt.Sprintf("matchArm-%d", i)))
+	}
+	mergeBlock := llvm.AddBasicBlock(g.currentFunction, "matchMerge")
+
+	// what happens when no pattern matches
+	fallback := mergeBlock
+	if wildcard >= 0 {
+		fallback = armBlocks[wildcard]
+	} else if missing := g.uncovered(subjectType, cases); len(missing) > 0 {
+		panic(fmt.Sprintf("match on '%s' doesn't handle %s, add arms for them or a '_' arm (line %d)", typeName(subjectType), strings.Join(missing, ", "), keyword.Line))
+	} else if isEnum || isBool {
+		fallback = llvm.AddBasicBlock(g.currentFunction, "matchUnreachable")
+		g.builder.SetInsertPointAtEnd(fallback)
+		g.builder.CreateUnreachable()
+	} else if isExpression {
+		panic(fmt.Sprintf("match expression on '%s' needs a '_' arm to have a value when nothing else matches (line %d)", typeName(subjectType), keyword.Line))
+	}
+
+	// constants go through a switch and whatever it doesn't catch is checked against the ranges in order
+	g.builder.SetInsertPointAtEnd(dispatchBlock)
+	tests := cases
+	if isSwitch {
+		tests = make([]matchCase, 0)
+		constants := make([]matchCase, 0)
+		for _, matchCase := range cases {
+			if matchCase.isRange() {
+				tests = append(tests, matchCase)
+			} else {
+				constants = append(constants, matchCase)
+			}
+		}
+		rangeBlock := fallback
+		if len(tests) > 0 {
+			rangeBlock = llvm.AddBasicBlock(g.currentFunction, "matchRanges")
+		}
+		switchInst := g.builder.CreateSwitch(subjectVal, rangeBlock, len(constants))
+		for _, matchCase := range constants {
+			switchInst.AddCase(matchCase.value, armBlocks[matchCase.arm])
+		}
+		if len(tests) == 0 {
+			return armBlocks, mergeBlock
+		}
+		g.builder.SetInsertPointAtEnd(rangeBlock)
+	}
+	for _, matchCase := range tests {
+		var matches llvm.Value
+		if matchCase.isRange() {
+			atLeast := g.binaryOp(scanner.Token{Type: scanner.GREATER_EQ, Lexeme: ">=", Line: matchCase.line}, subjectType, subjectVal, matchCase.value)
+			below := g.binaryOp(scanner.Token{Type: scanner.LESS, Lexeme: "<", Line: matchCase.line}, subjectType, subjectVal, matchCase.end)
+			matches = g.builder.CreateAnd(atLeast, below, "inRange")
+		} else {
+			matches = g.binaryOp(scanner.Token{Type: scanner.EQUAL, Lexeme: "==", Line: matchCase.line}, subjectType, subjectVal, matchCase.value)
+		}
+		nextBlock := llvm.AddBasicBlock(g.currentFunction, "matchNext")
+		g.builder.CreateCondBr(matches, armBlocks[matchCase.arm], nextBlock)
+		g.builder.SetInsertPointAtEnd(nextBlock)
+	}
+	g.builder.CreateBr(fallback)
+	return armBlocks, mergeBlock
+}
+
+// patterns are constants of the subject's type, number literals become whichever numeric type that is
+func (g *IRGenerator) patternValue(expr ast.Expr, subjectType ast.Type, line int) llvm.Value {
+	value := g.coerce(expr, g.evaluate(expr), subjectType, line)
+	patternType := g.typeOf(expr)
+	if !(isNumeric(patternType) && isNumeric(subjectType)) && typeName(patternType) != typeName(subjectType) {
+		panic(fmt.Sprintf("pattern of type '%s' can't match a value of type '%s' (line %d)", typeName(patternType), typeName(subjectType), line))
+	}
+	if !value.IsConstant() {
+		panic(fmt.Sprintf("match patterns must be constants (line %d)", line))
+	}
+	return value
+}
+
+// constants repeated in a later arm, or already inside of an earlier range, could never be matched
+func checkReachable(cases []matchCase, isUnsigned bool) {
+	intValue := func(value llvm.Value) int64 {
+		if isUnsigned {
+			return int64(value.ZExtValue())
+		}
+		return value.SExtValue()
+	}
+	inRange := func(value int64, rangeCase matchCase) bool {
+		if isUnsigned {
+			return uint64(value) >= rangeCase.value.ZExtValue() && uint64(value) < rangeCase.end.ZExtValue()
+		}
+		return value >= intValue(rangeCase.value) && value < intValue(rangeCase.end)
+	}
+	for i, matchCase := range cases {
+		if matchCase.isRange() {
+			if !inRange(intValue(matchCase.value), matchCase) {
+				panic(fmt.Sprintf("range pattern is empty since its end isn't after its start (line %d)", matchCase.line))
+			}
+			continue
+		}
+		value := intValue(matchCase.value)
+		for _, earlier := range cases[:i] {
+			if (earlier.isRange() && inRange(value, earlier)) || (!earlier.isRange() && intValue(earlier.value) == value) {
+				panic(fmt.Sprintf("pattern is already matched by an earlier one (line %d)", matchCase.line))
+			}
+		}
+	}
+}
+
+// the enum variants or bools no pattern matches. other types are never fully covered without a '_'
+func (g *IRGenerator) uncovered(subjectType ast.Type, cases []matchCase) []string {
+	covered := make(map[uint64]bool)
+	for _, matchCase := range cases {
+		if !matchCase.isRange() && matchCase.value.Type().TypeKind() == llvm.IntegerTypeKind {
+			covered[matchCase.value.ZExtValue()] = true
+		}
+	}
+	missing := make([]string, 0)
+	if enum, isEnum := g.enums[subjectType.Token.Lexeme]; isEnum {
+		for i, variant := range enum.variants {
+			if !covered[uint64(i)] {
+				missing = append(missing, subjectType.Token.Lexeme+"."+variant.Lexeme)
+			}
+		}
+	} else if subjectType.Token.Lexeme == "bool" {
+		for i, name := range []string{"false", "true"} {
+			if !covered[uint64(i)] {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return missing
+}
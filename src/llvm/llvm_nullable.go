@@ -0,0 +1,154 @@
+package llvm
+
+import (
+	"fmt"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/environment"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// nil has a type of its own until it's stored somewhere, at which point it becomes a null pointer of that type
+func nilType(keyword scanner.Token) ast.Type {
+	return ast.Type{Token: keyword, IsNullable: true}
+}
+
+func isNil(type_ ast.Type) bool {
+	return type_.Token.Type == scanner.NIL
+}
+
+func (g *IRGenerator) VisitNilExpr(expr *ast.NilExpr) llvm.Value {
+	g.exprTypes[expr] = nilType(expr.Keyword)
+	return llvm.ConstPointerNull(llvm.PointerType(g.ctx.Int8Type(), 0))
+}
+
+// p.? is a pointer that is known not to be nil, getting there with a nil pointer is a runtime error
+func (g *IRGenerator) VisitUnwrapExpr(expr *ast.UnwrapExpr) llvm.Value {
+	ptr := g.evaluate(expr.Expression)
+	ptrType := g.typeOf(expr.Expression)
+	if isNil(ptrType) {
+		panic(fmt.Sprintf("cannot unwrap nil (line %d)", expr.Operator.Line))
+	}
+	if !ptrType.IsNullable {
+		panic(fmt.Sprintf("'.?' can only unwrap nullable pointers (line %d)", expr.Operator.Line))
+	}
+	isNotNil := g.builder.CreateICmp(llvm.IntNE, ptr, llvm.ConstPointerNull(ptr.Type()), "isNotNil")
+	g.trapUnless(isNotNil, fmt.Sprintf("unwrapped a nil pointer (line %d)", expr.Operator.Line))
+	ptrType.IsNullable = false
+	g.exprTypes[expr] = ptrType
+	return ptr
+}
+
+// dereferencing needs a pointer that can't be nil, either from a nil check or from unwrapping it
+func checkDereference(ptrType ast.Type, line int) {
+	if ptrType.IsNullable {
+		panic(fmt.Sprintf("cannot dereference a nullable pointer, check it against nil or unwrap it with '.?' (line %d)", line))
+	}
+}
+
+// variables that must be non-nil when condition is true, from checks like p != nil and q != nil
+func nonNilWhenTrue(condition ast.Expr) []scanner.Token {
+	switch expr := condition.(type) {
+	case *ast.GroupingExpr:
+		return nonNilWhenTrue(expr.Expression)
+	case *ast.LogicalExpr:
+		if expr.Operator.Type == scanner.AND {
+			return append(nonNilWhenTrue(expr.Left), nonNilWhenTrue(expr.Right)...)
+		}
+	case *ast.BinaryExpr:
+		if expr.Operator.Type == scanner.NOT_EQUAL {
+			return nilComparison(expr)
+		}
+	}
+	return nil
+}
+
+// variables that must be non-nil when condition is false, from checks like p == nil or q == nil
+func nonNilWhenFalse(condition ast.Expr) []scanner.Token {
+	switch expr := condition.(type) {
+	case *ast.GroupingExpr:
+		return nonNilWhenFalse(expr.Expression)
+	case *ast.LogicalExpr:
+		if expr.Operator.Type == scanner.OR {
+			return append(nonNilWhenFalse(expr.Left), nonNilWhenFalse(expr.Right)...)
+		}
+	case *ast.UnaryExpr:
+		if expr.Operator.Type == scanner.BANG {
+			return nonNilWhenTrue(expr.Right)
+		}
+	case *ast.BinaryExpr:
+		if expr.Operator.Type == scanner.EQUAL {
+			return nilComparison(expr)
+		}
+	}
+	return nil
+}
+
+// the variable compared against nil in p == nil, nil == p and their != versions
+func nilComparison(expr *ast.BinaryExpr) []scanner.Token {
+	left, leftIsVariable := expr.Left.(*ast.IdentifierExpr)
+	right, rightIsVariable := expr.Right.(*ast.IdentifierExpr)
+	_, leftIsNil := expr.Left.(*ast.NilExpr)
+	_, rightIsNil := expr.Right.(*ast.NilExpr)
+	if leftIsVariable && rightIsNil {
+		return []scanner.Token{left.Value}
+	}
+	if rightIsVariable && leftIsNil {
+		return []scanner.Token{right.Value}
+	}
+	return nil
+}
+
+// opens a scope where the given nullable variables are treated as non-nullable, returning the scope to restore afterwards
+func (g *IRGenerator) narrow(nonNil []scanner.Token) *environment.Environment[variable] {
+	prevEnv := g.environment
+	g.environment = environment.NewEnvironment[variable](prevEnv)
+	g.narrowScope(nonNil)
+	return prevEnv
+}
+
+// treats the given nullable variables as non-nullable for the rest of the current scope
+func (g *IRGenerator) narrowScope(nonNil []scanner.Token) {
+	for _, name := range nonNil {
+		variable, exists := g.environment.Get(name.Lexeme)
+		// any call could set a variable shared with closures back to nil, so a check can't narrow it
+		if !exists || !variable.type_.IsNullable || !variable.type_.IsPointer || variable.boxed {
+			continue
+		}
+		variable.type_.IsNullable = false
+		variable.narrowed = true
+		variable.narrowedLoops = len(g.loops)
+		g.environment.Define(name.Lexeme)
+		g.environment.Set(name.Lexeme, variable)
+	}
+}
+
+// assigning something that may be nil to a variable that was checked against nil undoes the check from here on.
+// inside a loop nested in the check, code before the assignment would see it on the next iteration, so it's an error
+func (g *IRGenerator) widen(target ast.Expr, valueType ast.Type, line int) {
+	identifier, ok := target.(*ast.IdentifierExpr)
+	if !ok || !valueType.IsNullable {
+		return
+	}
+	variable, exists := g.environment.Get(identifier.Value.Lexeme)
+	if !exists || !variable.narrowed {
+		return
+	}
+	if len(g.loops) > variable.narrowedLoops {
+		panic(fmt.Sprintf("cannot assign a nullable pointer to '%s' inside a loop after checking it against nil (line %d)", identifier.Value.Lexeme, line))
+	}
+	variable.type_.IsNullable = true
+	variable.narrowed = false
+	g.environment.Assign(identifier.Value.Lexeme, variable)
+}
+
+// the type nil is converted to when compared against expr, which has to be a pointer
+func (g *IRGenerator) nullableTypeOf(expr ast.Expr, operator scanner.Token) ast.Type {
+	exprType := g.typeOf(expr)
+	if !exprType.IsPointer {
+		panic(fmt.Sprintf("nil can only be compared with pointers (line %d)", operator.Line))
+	}
+	exprType.IsNullable = true
+	return exprType
+}
@@ -0,0 +1,307 @@
+package llvm
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"math"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// bit widths of the sized numeric types. number is another name for f64
+var numericBits = map[string]int{
+	"i8": 8, "i16": 16, "i32": 32, "i64": 64,
+	"u8": 8, "u16": 16, "u32": 32, "u64": 64,
+	"f32": 32, "f64": 64, "number": 64,
+}
+
+func isNumeric(type_ ast.Type) bool {
+	_, exists := numericBits[type_.Token.Lexeme]
+	return exists && !type_.IsPointer && !type_.IsArray && !type_.IsSlice && !type_.IsErrorUnion
+}
+
+func isFloat(type_ ast.Type) bool {
+	name := type_.Token.Lexeme
+	return isNumeric(type_) && (name == "f32" || name == "f64" || name == "number")
+}
+
+func isUnsigned(type_ ast.Type) bool {
+	return isNumeric(type_) && type_.Token.Lexeme[0] == 'u'
+}
+
+func isInteger(type_ ast.Type) bool {
+	return isNumeric(type_) && !isFloat(type_)
+}
+
+func numericName(type_ ast.Type) string {
+	if type_.Token.Lexeme == "number" {
+		return "f64"
+	}
+	return type_.Token.Lexeme
+}
+
+func sameNumericType(a ast.Type, b ast.Type) bool {
+	return numericName(a) == numericName(b)
+}
+
+func isFloatValue(value llvm.Value) bool {
+	kind := value.Type().TypeKind()
+	return kind == llvm.FloatTypeKind || kind == llvm.DoubleTypeKind
+}
+
+// the value of a number literal, or of arithmetic on number literals like -1 or 60 * 60.
+// such constants have no type of their own and take on the type of wherever they're used. they're worked out
+// exactly, so integers too large for a float64 keep every digit until they're given a type
+func literalValue(expr ast.Expr) (constant.Value, bool) {
+	switch literal := expr.(type) {
+	case *ast.NumberExpr:
+		// a suffix like 255u8 fixes the type, so it's no longer an untyped constant
+		return literal.Value, literal.Suffix == nil
+	case *ast.GroupingExpr:
+		return literalValue(literal.Expression)
+	case *ast.UnaryExpr:
+		value, ok := literalValue(literal.Right)
+		if ok && literal.Operator.Lexeme == "-" {
+			return constant.UnaryOp(token.SUB, value, 0), true
+		}
+		if ok && literal.Operator.Lexeme == "~" && isWhole(value) {
+			return constant.UnaryOp(token.XOR, constant.ToInt(value), 0), true
+		}
+	case *ast.BinaryExpr:
+		lhs, lhsOk := literalValue(literal.Left)
+		rhs, rhsOk := literalValue(literal.Right)
+		if !lhsOk || !rhsOk {
+			return nil, false
+		}
+		switch literal.Operator.Lexeme {
+		case "+":
+			return constant.BinaryOp(lhs, token.ADD, rhs), true
+		case "-":
+			return constant.BinaryOp(lhs, token.SUB, rhs), true
+		case "*":
+			return constant.BinaryOp(lhs, token.MUL, rhs), true
+		case "/", "%":
+			if constant.Sign(rhs) == 0 {
+				panic(fmt.Sprintf("division of constants by zero (line %d)", literal.Operator.Line))
+			}
+			if literal.Operator.Lexeme == "/" {
+				// dividing integers keeps the fraction, 7 / 2 is 3.5 like it is for number
+				return constant.BinaryOp(lhs, token.QUO, rhs), true
+			}
+			if isWhole(lhs) && isWhole(rhs) {
+				return constant.BinaryOp(constant.ToInt(lhs), token.REM, constant.ToInt(rhs)), true
+			}
+			lhsFloat, _ := constant.Float64Val(lhs)
+			rhsFloat, _ := constant.Float64Val(rhs)
+			return constant.MakeFloat64(math.Mod(lhsFloat, rhsFloat)), true
+		}
+		if !isWhole(lhs) || !isWhole(rhs) {
+			return nil, false
+		}
+		lhs, rhs = constant.ToInt(lhs), constant.ToInt(rhs)
+		switch literal.Operator.Lexeme {
+		case "&":
+			return constant.BinaryOp(lhs, token.AND, rhs), true
+		case "|":
+			return constant.BinaryOp(lhs, token.OR, rhs), true
+		case "^":
+			return constant.BinaryOp(lhs, token.XOR, rhs), true
+		case "<<", ">>":
+			return foldShift(literal.Operator, lhs, rhs), true
+		}
+	}
+	return nil, false
+}
+
+func isWhole(value constant.Value) bool {
+	return constant.ToInt(value).Kind() == constant.Int
+}
+
+// shifts of constants have to stay within 64 bits, so bits shifted past the top are an error instead of being lost
+func foldShift(operator scanner.Token, lhs constant.Value, rhs constant.Value) constant.Value {
+	if constant.Sign(rhs) < 0 {
+		panic(fmt.Sprintf("cannot shift by the negative count %s (line %d)", rhs, operator.Line))
+	}
+	count, isSmall := constant.Uint64Val(rhs)
+	if operator.Lexeme == ">>" {
+		// shifting further than 64 bits can't change anything more
+		if !isSmall || count > 64 {
+			count = 64
+		}
+		return constant.Shift(lhs, token.SHR, uint(count))
+	}
+	if !isSmall || count >= 64 || constant.BitLen(constant.Shift(lhs, token.SHL, uint(count))) > 64 {
+		panic(fmt.Sprintf("constant %s << %s overflows (line %d)", lhs, rhs, operator.Line))
+	}
+	return constant.Shift(lhs, token.SHL, uint(count))
+}
+
+// the type folded bitwise operations on literals get, u64 when the value only fits there like 1 << 63
+func foldedIntegerType(value constant.Value) ast.Type {
+	if constant.Sign(value) > 0 && constant.BitLen(value) == 64 {
+		return primitiveType("u64")
+	}
+	return primitiveType("i64")
+}
+
+// shifting by the number of bits in the value or more gives poison in llvm, so counts known up front must be
+// smaller and others abort when they aren't
+func (g *IRGenerator) checkShiftCount(operator scanner.Token, count llvm.Value) {
+	width := uint64(count.Type().IntTypeWidth())
+	if !count.IsAConstantInt().IsNil() {
+		if count.ZExtValue() >= width {
+			panic(fmt.Sprintf("cannot shift a %d bit value by %d (line %d)", width, count.SExtValue(), operator.Line))
+		}
+		return
+	}
+	inRange := g.builder.CreateICmp(llvm.IntULT, count, llvm.ConstInt(count.Type(), width, false), "shiftInRange")
+	g.trapUnless(inRange, fmt.Sprintf("shift count out of range (line %d)", operator.Line))
+}
+
+func isBitwiseOperator(operator scanner.TokenType) bool {
+	switch operator {
+	case scanner.ADDRESS, scanner.PIPE, scanner.CARET, scanner.SHIFT_LEFT, scanner.SHIFT_RIGHT:
+		return true
+	}
+	return false
+}
+
+// chars and bools only go with their own type. bools can be compared for equality and combined with & | and ^,
+// but they have no order and no arithmetic
+func checkCharAndBoolOperands(operator scanner.Token, lhsType ast.Type, rhsType ast.Type) {
+	for _, name := range []string{"char", "bool"} {
+		if isPrimitive(lhsType, name) != isPrimitive(rhsType, name) {
+			panic(fmt.Sprintf("mismatched types '%s' and '%s' for '%s' (line %d)", typeName(lhsType), typeName(rhsType), operator.Lexeme, operator.Line))
+		}
+	}
+	isEquality := operator.Type == scanner.EQUAL || operator.Type == scanner.NOT_EQUAL
+	if isPrimitive(lhsType, "bool") && !isEquality && !isBitwiseOperator(operator.Type) {
+		panic(fmt.Sprintf("operator '%s' is not supported on 'bool' (line %d)", operator.Lexeme, operator.Line))
+	}
+}
+
+// bitwise operators work on the bits of integers, bools can also be combined with & | and ^ but not shifted
+func (g *IRGenerator) checkBitwiseOperands(operator scanner.Token, operandType ast.Type) {
+	isBool := operandType.Token.Lexeme == "bool" && !operandType.IsPointer
+	isShift := operator.Type == scanner.SHIFT_LEFT || operator.Type == scanner.SHIFT_RIGHT
+	if isInteger(operandType) || (isBool && !isShift) {
+		return
+	}
+	panic(fmt.Sprintf("operator '%s' needs integer operands, got '%s' (line %d)", operator.Lexeme, operandType.Token.Lexeme, operator.Line))
+}
+
+// turns a literal into a constant of the given numeric type, checking that it fits
+func (g *IRGenerator) numericConstant(value constant.Value, target ast.Type, line int) llvm.Value {
+	llvmType := g.llvmTypeFromAstType(target)
+	if isFloat(target) {
+		float, _ := constant.Float64Val(value)
+		if target.Token.Lexeme == "f32" {
+			float32, _ := constant.Float32Val(value)
+			float = float64(float32)
+		}
+		if math.IsInf(float, 0) {
+			panic(fmt.Sprintf("constant %s overflows '%s' (line %d)", value, numericName(target), line))
+		}
+		return llvm.ConstFloat(llvmType, float)
+	}
+	if !isWhole(value) {
+		panic(fmt.Sprintf("constant %s is not a whole number so it can't be used as '%s' (line %d)", value, numericName(target), line))
+	}
+	value = constant.ToInt(value)
+	bits := numericBits[target.Token.Lexeme]
+	if isUnsigned(target) {
+		unsigned, fits := constant.Uint64Val(value)
+		if !fits || (bits < 64 && unsigned >= 1<<bits) {
+			panic(fmt.Sprintf("constant %s overflows '%s' (line %d)", value, numericName(target), line))
+		}
+		return llvm.ConstInt(llvmType, unsigned, false)
+	}
+	signed, fits := constant.Int64Val(value)
+	lowest := int64(-1) << (bits - 1)
+	if !fits || signed < lowest || signed > -(lowest+1) {
+		panic(fmt.Sprintf("constant %s overflows '%s' (line %d)", value, numericName(target), line))
+	}
+	return llvm.ConstInt(llvmType, uint64(signed), true)
+}
+
+// gives a literal operand the type of the other side of a binary expression, so x + 1 works for any numeric x
+func (g *IRGenerator) unifyLiteral(expr ast.Expr, value llvm.Value, other ast.Expr, line int) llvm.Value {
+	literal, isLiteral := literalValue(expr)
+	otherType := g.typeOf(other)
+	if !isLiteral || !isNumeric(otherType) || sameNumericType(g.typeOf(expr), otherType) {
+		return value
+	}
+	// between two literals, the one that has been given a type like 1 << 4 decides
+	if _, otherIsLiteral := literalValue(other); otherIsLiteral && otherType.Token.Lexeme == "number" {
+		return value
+	}
+	g.exprTypes[expr] = otherType
+	return g.numericConstant(literal, otherType, line)
+}
+
+func (g *IRGenerator) VisitCastExpr(expr *ast.CastExpr) llvm.Value {
+	value := g.evaluate(expr.Expression)
+	from := g.typeOf(expr.Expression)
+	to := g.resolveType(expr.Type)
+	g.exprTypes[expr] = to
+	if literal, ok := literalValue(expr.Expression); ok && isNumeric(to) && (isFloat(to) || isWhole(literal)) {
+		return g.numericConstant(literal, to, expr.Keyword.Line)
+	}
+	_, fromEnum := g.enums[from.Token.Lexeme]
+	fromBool := from.Token.Lexeme == "bool" && !from.IsPointer
+	fromChar := from.Token.Lexeme == "char" && !from.IsPointer
+	toChar := to.Token.Lexeme == "char" && !to.IsPointer
+	if !(isNumeric(from) || fromEnum || fromBool || fromChar) || !(isNumeric(to) || toChar) {
+		panic(fmt.Sprintf("cannot cast '%s' to '%s' (line %d)", typeName(from), typeName(to), expr.Keyword.Line))
+	}
+
+	llvmType := g.llvmTypeFromAstType(to)
+	switch {
+	case isFloat(from) && isFloat(to):
+		return g.builder.CreateFPCast(value, llvmType, "")
+	case isFloat(from) && isUnsigned(to):
+		return g.builder.CreateFPToUI(value, llvmType, "")
+	case isFloat(from):
+		return g.builder.CreateFPToSI(value, llvmType, "")
+	case isFloat(to) && (isUnsigned(from) || fromBool):
+		return g.builder.CreateUIToFP(value, llvmType, "")
+	case isFloat(to):
+		return g.builder.CreateSIToFP(value, llvmType, "")
+	}
+	// between integers, widening keeps the sign of signed values
+	fromBits, toBits := value.Type().IntTypeWidth(), llvmType.IntTypeWidth()
+	switch {
+	case fromBits > toBits:
+		return g.builder.CreateTrunc(value, llvmType, "")
+	case fromBits == toBits:
+		return value
+	case isUnsigned(from) || fromBool:
+		return g.builder.CreateZExt(value, llvmType, "")
+	default:
+		return g.builder.CreateSExt(value, llvmType, "")
+	}
+}
+
+// c varargs promote floats to double and small integers to int, so printf sees what it expects.
+// strings are passed as a pointer to their null terminated bytes
+func (g *IRGenerator) promoteVarArg(value llvm.Value, valueType ast.Type) llvm.Value {
+	if isString(valueType) {
+		return g.builder.CreateExtractValue(value, stringPtrIndex, "cstr")
+	}
+	switch value.Type().TypeKind() {
+	case llvm.FloatTypeKind:
+		return g.builder.CreateFPExt(value, g.ctx.DoubleType(), "")
+	case llvm.IntegerTypeKind:
+		if value.Type().IntTypeWidth() >= 32 {
+			return value
+		}
+		if isUnsigned(valueType) || valueType.Token.Lexeme == "bool" {
+			return g.builder.CreateZExt(value, g.ctx.Int32Type(), "")
+		}
+		return g.builder.CreateSExt(value, g.ctx.Int32Type(), "")
+	}
+	return value
+}
@@ -0,0 +1,285 @@
+package llvm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// strings are lowered to { ptr, len }. the bytes are immutable and always followed by a null byte,
+// so the pointer can be handed straight to c functions like printf
+const (
+	stringPtrIndex = 0
+	stringLenIndex = 1
+)
+
+func (g *IRGenerator) stringType() llvm.Type {
+	return g.ctx.StructType([]llvm.Type{llvm.PointerType(g.ctx.Int8Type(), 0), g.ctx.Int64Type()}, false)
+}
+
+func isString(type_ ast.Type) bool {
+	return type_.Token.Lexeme == "string" && !type_.IsPointer && !type_.IsArray && !type_.IsSlice && !type_.IsErrorUnion
+}
+
+func (g *IRGenerator) VisitStringExpr(expr *ast.StringExpr) llvm.Value {
+	g.exprTypes[expr] = primitiveType("string")
+	return g.stringLiteral(expr.Value)
+}
+
+func (g *IRGenerator) stringLiteral(value string) llvm.Value {
+	length := llvm.ConstInt(g.ctx.Int64Type(), uint64(len(value)), false)
+	return g.ctx.ConstStruct([]llvm.Value{g.stringLiteralPtr(value), length}, false)
+}
+
+// literals are private global constants, shared between every use of the same text
+func (g *IRGenerator) stringLiteralPtr(value string) llvm.Value {
+	if ptr, exists := g.stringLiterals[value]; exists {
+		return ptr
+	}
+	bytes := g.ctx.ConstString(value, true)
+	global := llvm.AddGlobal(g.module, bytes.Type(), ".str")
+	global.SetInitializer(bytes)
+	global.SetLinkage(llvm.PrivateLinkage)
+	global.SetGlobalConstant(true)
+	global.SetUnnamedAddr(true)
+
+	zero := llvm.ConstInt(g.ctx.Int64Type(), 0, false)
+	ptr := llvm.ConstInBoundsGEP(bytes.Type(), global, []llvm.Value{zero, zero})
+	g.stringLiterals[value] = ptr
+	return ptr
+}
+
+// "a {x} b" is formatted by snprintf into a new string. the format specifier for each embedded
+// value comes from its static type so it can never disagree with the argument
+func (g *IRGenerator) VisitInterpolationExpr(expr *ast.InterpolationExpr) llvm.Value {
+	format, args := g.interpolationFormat(expr)
+	i64 := g.ctx.Int64Type()
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	snprintf := g.runtimeFunction("snprintf", llvm.FunctionType(g.ctx.Int32Type(), []llvm.Type{bytePtr, i64, bytePtr}, true))
+	formatPtr := g.stringLiteralPtr(format)
+	// the first call only measures, the second writes into a buffer of that size
+	measureArgs := append([]llvm.Value{llvm.ConstPointerNull(bytePtr), llvm.ConstInt(i64, 0, false), formatPtr}, args...)
+	length := g.builder.CreateCall(snprintf.GlobalValueType(), snprintf, measureArgs, "")
+	length = g.builder.CreateSExt(length, i64, "len")
+	size := g.builder.CreateAdd(length, llvm.ConstInt(i64, 1, false), "size")
+	malloc := g.module.NamedFunction("malloc")
+	buf := g.builder.CreateCall(malloc.GlobalValueType(), malloc, []llvm.Value{size}, "buf")
+	g.builder.CreateCall(snprintf.GlobalValueType(), snprintf, append([]llvm.Value{buf, size, formatPtr}, args...), "")
+
+	result := g.builder.CreateInsertValue(llvm.Undef(g.stringType()), buf, stringPtrIndex, "")
+	result = g.builder.CreateInsertValue(result, length, stringLenIndex, "interpolated")
+	g.exprTypes[expr] = primitiveType("string")
+	return result
+}
+
+func (g *IRGenerator) interpolationFormat(expr *ast.InterpolationExpr) (string, []llvm.Value) {
+	format := escapeFormat(expr.Strings[0])
+	args := make([]llvm.Value, 0)
+	for i, value := range expr.Values {
+		specifier, formatArgs := g.formatValue(value, g.evaluate(value), expr.Start.Line)
+		format += specifier + escapeFormat(expr.Strings[i+1])
+		args = append(args, formatArgs...)
+	}
+	return format, args
+}
+
+// printf("a {x} b") prints the interpolated text as is. formatting it into a string first and handing that to
+// printf would make any % in the embedded values start a specifier
+func (g *IRGenerator) printInterpolation(expr *ast.CallExpr, interpolation *ast.InterpolationExpr, printf variable) llvm.Value {
+	if len(expr.Args) > 1 {
+		panic(fmt.Sprintf("printf takes no other arguments when its format is an interpolated string (line %d)", expr.Paren.Line))
+	}
+	format, args := g.interpolationFormat(interpolation)
+	g.exprTypes[interpolation] = primitiveType("string")
+	g.exprTypes[expr] = printf.type_
+	return g.createCall(printf.value, append([]llvm.Value{g.stringLiteralPtr(format)}, args...))
+}
+
+// the text around embedded values is copied as is, so any % in it must not start a specifier
+func escapeFormat(text string) string {
+	return strings.ReplaceAll(text, "%", "%%")
+}
+
+// the printf specifier for a value of the expression's type and the arguments that go with it
+func (g *IRGenerator) formatValue(expr ast.Expr, value llvm.Value, line int) (string, []llvm.Value) {
+	valueType := g.typeOf(expr)
+	_, isEnum := g.enums[valueType.Token.Lexeme]
+	switch {
+	case isNil(valueType):
+		return "nil", nil
+	case valueType.IsPointer:
+		return "%p", []llvm.Value{value}
+	case isString(valueType):
+		// the length is passed along so strings holding \0 aren't cut short
+		length := g.builder.CreateTrunc(g.builder.CreateExtractValue(value, stringLenIndex, ""), g.ctx.Int32Type(), "")
+		return "%.*s", []llvm.Value{length, g.builder.CreateExtractValue(value, stringPtrIndex, "")}
+	case isFloat(valueType):
+		// enough digits to tell values apart without printing noise like 0.10000000000000001
+		specifier := "%.15g"
+		if valueType.Token.Lexeme == "f32" {
+			specifier = "%.7g"
+		}
+		return specifier, []llvm.Value{g.promoteVarArg(value, valueType)}
+	case isInteger(valueType):
+		specifier := "%d"
+		if isUnsigned(valueType) {
+			specifier = "%u"
+		}
+		if numericBits[valueType.Token.Lexeme] == 64 {
+			specifier = "%l" + specifier[1:]
+		}
+		return specifier, []llvm.Value{g.promoteVarArg(value, valueType)}
+	case valueType.Token.Lexeme == "char":
+		return "%c", []llvm.Value{g.promoteVarArg(value, valueType)}
+	case valueType.Token.Lexeme == "bool":
+		name := g.builder.CreateSelect(value, g.stringLiteralPtr("true"), g.stringLiteralPtr("false"), "")
+		return "%s", []llvm.Value{name}
+	case isEnum:
+		return "%s", []llvm.Value{g.enumVariantName(valueType.Token.Lexeme, value)}
+	default:
+		panic(fmt.Sprintf("cannot interpolate a value of type '%s' (line %d)", typeName(valueType), line))
+	}
+}
+
+// enum values print as the name of their variant, looked up in a table of names for that enum
+func (g *IRGenerator) enumVariantName(enumName string, value llvm.Value) llvm.Value {
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	variants := g.enums[enumName].variants
+	tableType := llvm.ArrayType(bytePtr, len(variants))
+	table := g.module.NamedGlobal(enumName + ".names")
+	if table.IsNil() {
+		names := make([]llvm.Value, 0)
+		for _, variant := range variants {
+			names = append(names, g.stringLiteralPtr(variant.Lexeme))
+		}
+		table = llvm.AddGlobal(g.module, tableType, enumName+".names")
+		table.SetInitializer(llvm.ConstArray(bytePtr, names))
+		table.SetLinkage(llvm.PrivateLinkage)
+		table.SetGlobalConstant(true)
+	}
+	zero := llvm.ConstInt(g.ctx.Int64Type(), 0, false)
+	index := g.builder.CreateZExt(value, g.ctx.Int64Type(), "")
+	namePtr := g.builder.CreateInBoundsGEP(tableType, table, []llvm.Value{zero, index}, "")
+	return g.builder.CreateLoad(bytePtr, namePtr, "variantName")
+}
+
+// strings support + to concatenate and == != to compare their contents, both operands have to be strings
+func checkStringOperands(operator scanner.Token, lhsType ast.Type, rhsType ast.Type) {
+	switch operator.Type {
+	case scanner.PLUS, scanner.EQUAL, scanner.NOT_EQUAL:
+	default:
+		panic(fmt.Sprintf("operator '%s' is not supported on strings (line %d)", operator.Lexeme, operator.Line))
+	}
+	if !isString(lhsType) || !isString(rhsType) {
+		panic(fmt.Sprintf("mismatched types '%s' and '%s' for '%s' (line %d)", lhsType.Token.Lexeme, rhsType.Token.Lexeme, operator.Lexeme, operator.Line))
+	}
+}
+
+func (g *IRGenerator) stringOp(operator scanner.Token, lhsVal llvm.Value, rhsVal llvm.Value) llvm.Value {
+	switch operator.Type {
+	case scanner.PLUS:
+		concat := g.stringConcatFunction()
+		return g.builder.CreateCall(concat.GlobalValueType(), concat, []llvm.Value{lhsVal, rhsVal}, "concat")
+	case scanner.EQUAL:
+		equal := g.stringEqualFunction()
+		return g.builder.CreateCall(equal.GlobalValueType(), equal, []llvm.Value{lhsVal, rhsVal}, "equal")
+	case scanner.NOT_EQUAL:
+		equal := g.stringEqualFunction()
+		isEqual := g.builder.CreateCall(equal.GlobalValueType(), equal, []llvm.Value{lhsVal, rhsVal}, "")
+		return g.builder.CreateNot(isEqual, "not equal")
+	default:
+		panic(fmt.Sprintf("operator '%s' is not supported on strings (line %d)", operator.Lexeme, operator.Line))
+	}
+}
+
+// address of s[i] after checking that i is within the string's length. each element is a char
+func (g *IRGenerator) stringElementAddress(expr *ast.IndexExpr, strPtr llvm.Value) llvm.Value {
+	str := g.builder.CreateLoad(g.stringType(), strPtr, "")
+	ptr := g.builder.CreateExtractValue(str, stringPtrIndex, "ptr")
+	length := g.builder.CreateExtractValue(str, stringLenIndex, "len")
+	index := g.evaluateIndex(expr.Index, expr.Bracket)
+	inBounds := g.builder.CreateICmp(llvm.IntULT, index, length, "inBounds")
+	g.trapUnless(inBounds, fmt.Sprintf("index out of bounds (line %d)", expr.Bracket.Line))
+	g.exprTypes[expr] = primitiveType("char")
+	return g.builder.CreateInBoundsGEP(g.ctx.Int8Type(), ptr, []llvm.Value{index}, "charPtr")
+}
+
+// the runtime functions below are written into the module the first time a program needs them.
+// they're internal so every module carries its own copy and nothing has to be linked in afterwards
+
+// kel.string.concat(a, b) copies both strings into a fresh null terminated allocation
+func (g *IRGenerator) stringConcatFunction() llvm.Value {
+	const name = "kel.string.concat"
+	if fn := g.module.NamedFunction(name); !fn.IsNil() {
+		return fn
+	}
+	strType := g.stringType()
+	i64 := g.ctx.Int64Type()
+	fn := llvm.AddFunction(g.module, name, llvm.FunctionType(strType, []llvm.Type{strType, strType}, false))
+	fn.SetLinkage(llvm.InternalLinkage)
+
+	builder := g.ctx.NewBuilder()
+	defer builder.Dispose()
+	builder.SetInsertPointAtEnd(llvm.AddBasicBlock(fn, "entry"))
+	lhsPtr := builder.CreateExtractValue(fn.Param(0), stringPtrIndex, "lhsPtr")
+	lhsLen := builder.CreateExtractValue(fn.Param(0), stringLenIndex, "lhsLen")
+	rhsPtr := builder.CreateExtractValue(fn.Param(1), stringPtrIndex, "rhsPtr")
+	rhsLen := builder.CreateExtractValue(fn.Param(1), stringLenIndex, "rhsLen")
+	length := builder.CreateAdd(lhsLen, rhsLen, "len")
+
+	malloc := g.module.NamedFunction("malloc")
+	memcpy := g.module.NamedFunction("memcpy")
+	size := builder.CreateAdd(length, llvm.ConstInt(i64, 1, false), "size")
+	buf := builder.CreateCall(malloc.GlobalValueType(), malloc, []llvm.Value{size}, "buf")
+	builder.CreateCall(memcpy.GlobalValueType(), memcpy, []llvm.Value{buf, lhsPtr, lhsLen}, "")
+	rhsDest := builder.CreateInBoundsGEP(g.ctx.Int8Type(), buf, []llvm.Value{lhsLen}, "")
+	builder.CreateCall(memcpy.GlobalValueType(), memcpy, []llvm.Value{rhsDest, rhsPtr, rhsLen}, "")
+	terminator := builder.CreateInBoundsGEP(g.ctx.Int8Type(), buf, []llvm.Value{length}, "")
+	builder.CreateStore(llvm.ConstInt(g.ctx.Int8Type(), 0, false), terminator)
+
+	result := builder.CreateInsertValue(llvm.Undef(strType), buf, stringPtrIndex, "")
+	result = builder.CreateInsertValue(result, length, stringLenIndex, "")
+	builder.CreateRet(result)
+	return fn
+}
+
+// kel.string.equal(a, b) compares lengths first and only then the bytes
+func (g *IRGenerator) stringEqualFunction() llvm.Value {
+	const name = "kel.string.equal"
+	if fn := g.module.NamedFunction(name); !fn.IsNil() {
+		return fn
+	}
+	strType := g.stringType()
+	bytePtr := llvm.PointerType(g.ctx.Int8Type(), 0)
+	fn := llvm.AddFunction(g.module, name, llvm.FunctionType(g.ctx.Int1Type(), []llvm.Type{strType, strType}, false))
+	fn.SetLinkage(llvm.InternalLinkage)
+	memcmp := g.runtimeFunction("memcmp", llvm.FunctionType(g.ctx.Int32Type(), []llvm.Type{bytePtr, bytePtr, g.ctx.Int64Type()}, false))
+
+	builder := g.ctx.NewBuilder()
+	defer builder.Dispose()
+	entry := llvm.AddBasicBlock(fn, "entry")
+	compareBlock := llvm.AddBasicBlock(fn, "compare")
+	doneBlock := llvm.AddBasicBlock(fn, "done")
+
+	builder.SetInsertPointAtEnd(entry)
+	lhsLen := builder.CreateExtractValue(fn.Param(0), stringLenIndex, "lhsLen")
+	rhsLen := builder.CreateExtractValue(fn.Param(1), stringLenIndex, "rhsLen")
+	sameLength := builder.CreateICmp(llvm.IntEQ, lhsLen, rhsLen, "sameLen")
+	builder.CreateCondBr(sameLength, compareBlock, doneBlock)
+
+	builder.SetInsertPointAtEnd(compareBlock)
+	lhsPtr := builder.CreateExtractValue(fn.Param(0), stringPtrIndex, "lhsPtr")
+	rhsPtr := builder.CreateExtractValue(fn.Param(1), stringPtrIndex, "rhsPtr")
+	diff := builder.CreateCall(memcmp.GlobalValueType(), memcmp, []llvm.Value{lhsPtr, rhsPtr, lhsLen}, "diff")
+	sameBytes := builder.CreateICmp(llvm.IntEQ, diff, llvm.ConstInt(g.ctx.Int32Type(), 0, false), "sameBytes")
+	builder.CreateBr(doneBlock)
+
+	builder.SetInsertPointAtEnd(doneBlock)
+	result := builder.CreatePHI(g.ctx.Int1Type(), "equal")
+	result.AddIncoming([]llvm.Value{llvm.ConstInt(g.ctx.Int1Type(), 0, false), sameBytes}, []llvm.BasicBlock{entry, compareBlock})
+	builder.CreateRet(result)
+	return fn
+}
@@ -0,0 +1,93 @@
+package llvm
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus1400/kel/src/ast"
+	"github.com/prometheus1400/kel/src/scanner"
+	"tinygo.org/x/go-llvm"
+)
+
+// tuples are lowered to anonymous structs holding their elements in order, so functions returning several
+// values return one of those by value instead of writing the rest through out pointers
+
+func (g *IRGenerator) tupleType(type_ ast.Type) llvm.Type {
+	elems := make([]llvm.Type, 0)
+	for _, elem := range type_.Elems {
+		elems = append(elems, g.llvmTypeFromAstType(elem))
+	}
+	return g.ctx.StructType(elems, false)
+}
+
+func (g *IRGenerator) VisitTupleExpr(expr *ast.TupleExpr) llvm.Value {
+	values := make([]llvm.Value, 0)
+	elemTypes := make([]ast.Type, 0)
+	llvmTypes := make([]llvm.Type, 0)
+	for _, element := range expr.Elements {
+		value := g.evaluate(element)
+		values = append(values, value)
+		elemTypes = append(elemTypes, g.typeOf(element))
+		llvmTypes = append(llvmTypes, value.Type())
+	}
+	tupleVal := llvm.ConstNull(g.ctx.StructType(llvmTypes, false))
+	for i, value := range values {
+		tupleVal = g.builder.CreateInsertValue(tupleVal, value, i, "")
+	}
+	g.exprTypes[expr] = ast.Type{Token: expr.Paren, IsTuple: true, Elems: elemTypes}
+	return tupleVal
+}
+
+// rebuilds a tuple literal with each element converted to the target's type for it, like (q, 0) as (i32, i32)
+func (g *IRGenerator) tupleLiteral(expr *ast.TupleExpr, value llvm.Value, target ast.Type, line int) llvm.Value {
+	tupleVal := llvm.ConstNull(g.llvmTypeFromAstType(target))
+	for i, element := range expr.Elements {
+		elementVal := g.coerce(element, g.builder.CreateExtractValue(value, i, ""), target.Elems[i], line)
+		if elementVal.Type() != g.llvmTypeFromAstType(target.Elems[i]) {
+			panic(fmt.Sprintf("cannot use a value of type '%s' as '%s' (line %d)", typeName(g.typeOf(expr)), typeName(target), line))
+		}
+		tupleVal = g.builder.CreateInsertValue(tupleVal, elementVal, i, "")
+	}
+	g.exprTypes[expr] = target
+	return tupleVal
+}
+
+// t.0 is the first element of the tuple t
+func (g *IRGenerator) tupleElementAddress(expr *ast.GetExpr, tuplePtr llvm.Value, tupleType ast.Type) llvm.Value {
+	if expr.Name.Type != scanner.NUMBER {
+		panic(fmt.Sprintf("tuple '%s' has no field '%s', its elements are used by index like '.0' (line %d)", typeName(tupleType), expr.Name.Lexeme, expr.Name.Line))
+	}
+	index, err := strconv.Atoi(expr.Name.Lexeme)
+	if err != nil || index >= len(tupleType.Elems) {
+		panic(fmt.Sprintf("tuple '%s' has no element %s (line %d)", typeName(tupleType), expr.Name.Lexeme, expr.Name.Line))
+	}
+	g.exprTypes[expr] = tupleType.Elems[index]
+	return g.builder.CreateStructGEP(g.tupleType(tupleType), tuplePtr, index, "elementPtr")
+}
+
+// let (q, r) = divmod(a, b); defines a variable for each element of the tuple, skipping those named _
+func (g *IRGenerator) VisitUnpackStmt(stmt *ast.UnpackStmt) {
+	tupleVal := g.evaluate(stmt.Initializer)
+	tupleType := g.typeOf(stmt.Initializer)
+	if !tupleType.IsTuple || tupleType.IsPointer {
+		panic(fmt.Sprintf("cannot unpack a value of type '%s', only tuples can be (line %d)", typeName(tupleType), stmt.Keyword.Line))
+	}
+	if len(stmt.Names) != len(tupleType.Elems) {
+		panic(fmt.Sprintf("cannot unpack a tuple of %d elements into %d variables (line %d)", len(tupleType.Elems), len(stmt.Names), stmt.Keyword.Line))
+	}
+	declared := make(map[string]bool)
+	for i, name := range stmt.Names {
+		if name.Lexeme == "_" {
+			continue
+		}
+		if declared[name.Lexeme] {
+			panic(fmt.Sprintf("'%s' is unpacked into more than once (line %d)", name.Lexeme, name.Line))
+		}
+		declared[name.Lexeme] = true
+		elemType := tupleType.Elems[i]
+		if isNil(elemType) {
+			panic(fmt.Sprintf("cannot infer the type of '%s' from nil (line %d)", name.Lexeme, name.Line))
+		}
+		g.declareVariable(name, elemType, g.builder.CreateExtractValue(tupleVal, i, name.Lexeme), stmt.IsConst)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 
@@ -10,6 +11,8 @@ import (
 	"github.com/prometheus1400/kel/src/scanner"
 )
 
+var checkDivision = flag.Bool("check-division", false, "trap at runtime when an integer is divided by zero")
+
 func runRepl() {
 	reader := bufio.NewReader(os.Stdin)
 	for {
@@ -48,6 +51,7 @@ func run(source []byte) {
 	}
 
 	gen := llvm.NewIRGenerator()
+	gen.CheckDivision = *checkDivision
 	gen.GenerateIR(stmts, "example")
 
 	// interpreter := interpreter.NewTreeWalkInterpreter()
@@ -55,13 +59,14 @@ func run(source []byte) {
 }
 
 func main() {
-	args := os.Args
+	flag.Parse()
+	args := flag.Args()
 
 	switch len(args) {
-	case 1:
+	case 0:
 		runRepl()
-	case 2:
-		runFile(args[1])
+	case 1:
+		runFile(args[0])
 	default:
 		fmt.Fprintf(os.Stderr, "error")
 	}
@@ -2,7 +2,9 @@ package parser
 
 import (
 	"fmt"
+	"go/constant"
 	"strconv"
+	"strings"
 
 	"github.com/prometheus1400/kel/src/ast"
 	"github.com/prometheus1400/kel/src/scanner"
@@ -13,12 +15,18 @@ type Precedence int
 const (
 	PREC_NONE       Precedence = iota
 	PREC_ASSIGNMENT            // =
+	PREC_CATCH                 // catch
 	PREC_OR                    // or
 	PREC_AND                   // and
 	PREC_EQUALITY              // ==
 	PREC_COMPARISON            // < > <= >=
+	PREC_BIT_OR                // |
+	PREC_BIT_XOR               // ^
+	PREC_BIT_AND               // &
+	PREC_SHIFT                 // << >>
 	PREC_TERM                  // + -
-	PREC_FACTOR                // * /
+	PREC_FACTOR                // * / %
+	PREC_CAST                  // as
 	PREC_UNARY                 // ! -
 	PREC_CALL                  // . ()
 	PREC_PRIMARY
@@ -45,53 +53,82 @@ type SugaredIfStatement struct {
 func NewParseTable() *ParseTable {
 	return &ParseTable{
 		table: map[scanner.TokenType]ParseRule{
-			scanner.LEFT_PAREN:  {grouping, call, PREC_CALL},
-			scanner.RIGHT_PAREN: {nil, nil, PREC_NONE},
-			scanner.LEFT_BRACE:  {nil, nil, PREC_NONE},
-			scanner.RIGHT_BRACE: {nil, nil, PREC_NONE},
-			scanner.LEFT_BRACK:  {nil, nil, PREC_NONE},
-			scanner.RIGHT_BRACK: {nil, nil, PREC_NONE},
-			scanner.SEMI_COLON:  {nil, nil, PREC_NONE},
-			scanner.COMMA:       {nil, nil, PREC_NONE},
-			scanner.PLUS:        {nil, binary, PREC_TERM},
-			scanner.MINUS:       {unary, binary, PREC_TERM},
-			scanner.STAR:        {unary, binary, PREC_FACTOR},
-			scanner.SLASH:       {nil, binary, PREC_FACTOR},
-			scanner.BANG:        {unary, nil, PREC_UNARY},
-			scanner.ADDRESS:     {unary, nil, PREC_UNARY},
-			scanner.ASSIGN:      {nil, nil, PREC_NONE},
-			scanner.DOT:         {nil, nil, PREC_NONE},
-			scanner.PLUSPLUS:    {nil, nil, PREC_NONE},
-			scanner.MINUSMINUS:  {nil, nil, PREC_NONE},
-			scanner.EQUAL:       {nil, binary, PREC_EQUALITY},
-			scanner.NOT_EQUAL:   {nil, binary, PREC_EQUALITY},
-			scanner.LESS:        {nil, binary, PREC_COMPARISON},
-			scanner.GREATER:     {nil, binary, PREC_COMPARISON},
-			scanner.LESS_EQ:     {nil, binary, PREC_COMPARISON},
-			scanner.GREATER_EQ:  {nil, binary, PREC_COMPARISON},
-			scanner.NUMBER:      {number, nil, PREC_PRIMARY},
-			scanner.STRING:      {string_, nil, PREC_PRIMARY},
-			scanner.CHAR:        {char, nil, PREC_PRIMARY},
-			scanner.BOOL:        {nil, nil, PREC_PRIMARY},
-			scanner.IDENTIFIER:  {variable, nil, PREC_PRIMARY},
-			scanner.LET:         {nil, nil, PREC_NONE},
-			scanner.TRUE:        {boolean, nil, PREC_NONE},
-			scanner.FALSE:       {boolean, nil, PREC_NONE},
-			scanner.FN:          {nil, nil, PREC_NONE},
-			scanner.IF:          {nil, nil, PREC_NONE},
-			scanner.ELSE:        {nil, nil, PREC_NONE},
-			scanner.RETURN:      {nil, nil, PREC_NONE},
-			scanner.EOF:         {nil, nil, PREC_NONE},
-			// scanner.DOTDOT:      {nil, nil, PREC_NONE},
+			scanner.LEFT_PAREN:   {grouping, call, PREC_CALL},
+			scanner.RIGHT_PAREN:  {nil, nil, PREC_NONE},
+			scanner.LEFT_BRACE:   {nil, nil, PREC_NONE},
+			scanner.RIGHT_BRACE:  {nil, nil, PREC_NONE},
+			scanner.LEFT_BRACK:   {arrayLiteral, index, PREC_CALL},
+			scanner.RIGHT_BRACK:  {nil, nil, PREC_NONE},
+			scanner.SEMI_COLON:   {nil, nil, PREC_NONE},
+			scanner.COMMA:        {nil, nil, PREC_NONE},
+			scanner.COLON:        {nil, nil, PREC_NONE},
+			scanner.QUESTION:     {nil, nil, PREC_NONE},
+			scanner.PLUS:         {nil, binary, PREC_TERM},
+			scanner.MINUS:        {unary, binary, PREC_TERM},
+			scanner.STAR:         {unary, binary, PREC_FACTOR},
+			scanner.SLASH:        {nil, binary, PREC_FACTOR},
+			scanner.MOD:          {nil, binary, PREC_FACTOR},
+			scanner.BANG:         {unary, nil, PREC_UNARY},
+			scanner.ADDRESS:      {unary, binary, PREC_BIT_AND},
+			scanner.PIPE:         {nil, binary, PREC_BIT_OR},
+			scanner.CARET:        {nil, binary, PREC_BIT_XOR},
+			scanner.TILDE:        {unary, nil, PREC_UNARY},
+			scanner.SHIFT_LEFT:   {nil, binary, PREC_SHIFT},
+			scanner.SHIFT_RIGHT:  {nil, binary, PREC_SHIFT},
+			scanner.ASSIGN:       {nil, assign, PREC_ASSIGNMENT},
+			scanner.DOT:          {nil, dot, PREC_CALL},
+			scanner.DOTDOT:       {nil, nil, PREC_NONE},
+			scanner.PLUSPLUS:     {prefixIncrement, postfixIncrement, PREC_CALL},
+			scanner.MINUSMINUS:   {prefixIncrement, postfixIncrement, PREC_CALL},
+			scanner.PLUS_ASSIGN:  {nil, assign, PREC_ASSIGNMENT},
+			scanner.MINUS_ASSIGN: {nil, assign, PREC_ASSIGNMENT},
+			scanner.STAR_ASSIGN:  {nil, assign, PREC_ASSIGNMENT},
+			scanner.SLASH_ASSIGN: {nil, assign, PREC_ASSIGNMENT},
+			scanner.MOD_ASSIGN:   {nil, assign, PREC_ASSIGNMENT},
+			scanner.EQUAL:        {nil, binary, PREC_EQUALITY},
+			scanner.NOT_EQUAL:    {nil, binary, PREC_EQUALITY},
+			scanner.LESS:         {nil, binary, PREC_COMPARISON},
+			scanner.GREATER:      {nil, binary, PREC_COMPARISON},
+			scanner.LESS_EQ:      {nil, binary, PREC_COMPARISON},
+			scanner.GREATER_EQ:   {nil, binary, PREC_COMPARISON},
+			scanner.NUMBER:       {number, nil, PREC_PRIMARY},
+			scanner.SUFFIX:       {nil, nil, PREC_NONE},
+			scanner.STRING:       {string_, nil, PREC_NONE},
+			scanner.STRING_PART:  {interpolation, nil, PREC_NONE},
+			scanner.CHAR:         {char, nil, PREC_PRIMARY},
+			scanner.BOOL:         {nil, nil, PREC_PRIMARY},
+			scanner.IDENTIFIER:   {variable, nil, PREC_PRIMARY},
+			scanner.TYPE:         {nil, nil, PREC_NONE},
+			scanner.LET:          {nil, nil, PREC_NONE},
+			scanner.CONST:        {nil, nil, PREC_NONE},
+			scanner.VAR:          {nil, nil, PREC_NONE},
+			scanner.TRUE:         {boolean, nil, PREC_NONE},
+			scanner.FALSE:        {boolean, nil, PREC_NONE},
+			scanner.FN:           {function, nil, PREC_NONE},
+			scanner.IF:           {nil, nil, PREC_NONE},
+			scanner.ELSE:         {nil, nil, PREC_NONE},
+			scanner.RETURN:       {nil, nil, PREC_NONE},
+			scanner.WHILE:        {nil, nil, PREC_NONE},
+			scanner.FOR:          {nil, nil, PREC_NONE},
+			scanner.IN:           {nil, nil, PREC_NONE},
+			scanner.BREAK:        {nil, nil, PREC_NONE},
+			scanner.CONTINUE:     {nil, nil, PREC_NONE},
+			scanner.STRUCT:       {nil, nil, PREC_NONE},
+			scanner.PUB:          {nil, nil, PREC_NONE},
+			scanner.ENUM:         {nil, nil, PREC_NONE},
+			scanner.INTERFACE:    {nil, nil, PREC_NONE},
+			scanner.MATCH:        {matchExpression, nil, PREC_NONE},
+			scanner.FAT_ARROW:    {nil, nil, PREC_NONE},
+			scanner.ERROR:        {errorValue, nil, PREC_NONE},
+			scanner.TRY:          {try, nil, PREC_UNARY},
+			scanner.CATCH:        {nil, catch, PREC_CATCH},
+			scanner.NIL:          {nil_, nil, PREC_NONE},
+			scanner.AS:           {nil, cast, PREC_CAST},
+			scanner.AND:          {nil, logical, PREC_AND},
+			scanner.OR:           {nil, logical, PREC_OR},
+			scanner.EOF:          {nil, nil, PREC_NONE},
 			// scanner.DOTDOTDOT:   {nil, nil, PREC_NONE},
-			// scanner.STRUCT:      {nil, nil, PREC_NONE},
-			// scanner.ENUM:        {nil, nil, PREC_NONE},
 			// scanner.ELIF:        {nil, nil, PREC_NONE},
-			// scanner.PUB:         {nil, nil, PREC_NONE},
-			// scanner.FOR:         {nil, nil, PREC_NONE},
-			// scanner.WHILE:       {nil, nil, PREC_NONE},
-			// scanner.BREAK:       {nil, nil, PREC_NONE},
-			// scanner.CONTINUE:    {nil, nil, PREC_NONE},
 			// scanner.IMPORT:      {nil, nil, PREC_NONE},
 		},
 	}
@@ -107,12 +144,14 @@ func (t *ParseTable) GetRule(type_ scanner.TokenType) ParseRule {
 }
 
 type Parser struct {
-	HadError   bool
-	Errors     []error
-	tokens     []scanner.Token
-	start      int
-	current    int
-	parseTable *ParseTable
+	HadError        bool
+	Errors          []error
+	tokens          []scanner.Token
+	start           int
+	current         int
+	loopDepth       int
+	noStructLiteral bool
+	parseTable      *ParseTable
 }
 
 func NewParser() *Parser {
@@ -125,6 +164,8 @@ func (p *Parser) Init() {
 	p.tokens = nil
 	p.start = 0
 	p.current = 0
+	p.loopDepth = 0
+	p.noStructLiteral = false
 	p.HadError = false
 	p.Errors = make([]error, 0)
 	if p.parseTable == nil {
@@ -138,9 +179,14 @@ func (p *Parser) Parse(tokens []scanner.Token) []ast.Stmt {
 
 	stmts := make([]ast.Stmt, 0)
 	for !p.isAtEnd() {
+		start := p.current
 		stmt, err := p.declaration()
 		if err != nil {
 			p.synchronize()
+			if p.current == start {
+				// a stray '}'
+				p.advance()
+			}
 		} else {
 			stmts = append(stmts, stmt)
 		}
@@ -156,10 +202,16 @@ func (p *Parser) ReportErrors() {
 }
 
 func (p *Parser) declaration() (ast.Stmt, error) {
-	if p.match(scanner.LET) {
+	if p.match(scanner.LET, scanner.VAR, scanner.CONST) {
 		return p.varDeclaration()
 	} else if p.match(scanner.FN) {
 		return p.fnDeclaration()
+	} else if p.match(scanner.STRUCT) {
+		return p.structDeclaration()
+	} else if p.match(scanner.ENUM) {
+		return p.enumDeclaration()
+	} else if p.match(scanner.INTERFACE) {
+		return p.interfaceDeclaration()
 	} else {
 		return p.statement()
 	}
@@ -172,24 +224,35 @@ func (p *Parser) statement() (ast.Stmt, error) {
 		return p.blockStmt()
 	} else if p.match(scanner.RETURN) {
 		return p.returnStmt()
+	} else if p.match(scanner.WHILE) {
+		return p.whileStmt()
+	} else if p.match(scanner.FOR) {
+		return p.forStmt()
+	} else if p.match(scanner.BREAK) {
+		return p.breakStmt()
+	} else if p.match(scanner.CONTINUE) {
+		return p.continueStmt()
+	} else if p.match(scanner.MATCH) {
+		return p.matchStmt()
 	} else {
 		return p.expressionStmt()
 	}
 }
 
+// let and var declare mutable variables, const ones can never be assigned to after their declaration
 func (p *Parser) varDeclaration() (ast.Stmt, error) {
+	isConst := p.prev().Type == scanner.CONST
+	if p.match(scanner.LEFT_PAREN) {
+		return p.unpackDeclaration(isConst)
+	}
 	name, err := p.consume(scanner.IDENTIFIER, "expect variable name")
 	if err != nil {
 		return nil, err
 	}
 
-	var typeToken scanner.Token = scanner.Token{Type: scanner.TYPE, Lexeme: "auto"}
-	isPointer := false
-	if p.match(scanner.STAR) {
-		isPointer = true
-	}
-	if p.check(scanner.TYPE) || p.check(scanner.IDENTIFIER) {
-		typeToken, err = p.consumeType("expected type after variable name in variable declaration")
+	var varType ast.Type = ast.Type{Token: scanner.Token{Type: scanner.TYPE, Lexeme: "auto"}}
+	if !p.check(scanner.ASSIGN) && !p.check(scanner.SEMI_COLON) {
+		varType, err = p.parseType("expected type after variable name in variable declaration")
 		if err != nil {
 			return nil, err
 		}
@@ -203,19 +266,52 @@ func (p *Parser) varDeclaration() (ast.Stmt, error) {
 		}
 	}
 
-	if typeToken.Lexeme == "auto" && isPointer {
-		p.errorAtCurrent("need to supply type with '*'")
-	}
-	if typeToken.Lexeme == "auto" && initializer == nil {
+	if varType.Token.Lexeme == "auto" && initializer == nil {
 		p.errorAtCurrent("cannot infer type without initializer")
 	}
+	if isConst && initializer == nil {
+		p.errorAtCurrent(fmt.Sprintf("const '%s' must be initialized", name.Lexeme))
+	}
 
 	_, err = p.consume(scanner.SEMI_COLON, "expect semicolon after variable declaration")
 	if err != nil {
 		return nil, err
 	}
 
-	return &ast.VarStmt{Name: name, Type: ast.Type{Token: typeToken, IsPointer: isPointer}, Initializer: initializer}, nil
+	return &ast.VarStmt{Name: name, Type: varType, Initializer: initializer, IsConst: isConst}, nil
+}
+
+// let (q, r) = divmod(a, b); declares a variable for each element of a tuple, _ skips one
+func (p *Parser) unpackDeclaration(isConst bool) (ast.Stmt, error) {
+	keyword := p.prev()
+	names := make([]scanner.Token, 0)
+	for {
+		name, err := p.consume(scanner.IDENTIFIER, "expect variable name")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+	_, err := p.consume(scanner.RIGHT_PAREN, "expect ')' after variable names")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.ASSIGN, "expect '=' after variable names, unpacked variables must be initialized")
+	if err != nil {
+		return nil, err
+	}
+	initializer, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.SEMI_COLON, "expect ';' after variable declaration")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.UnpackStmt{Keyword: keyword, Names: names, Initializer: initializer, IsConst: isConst}, nil
 }
 
 func (p *Parser) fnDeclaration() (ast.Stmt, error) {
@@ -223,7 +319,32 @@ func (p *Parser) fnDeclaration() (ast.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
+	return p.function(name)
+}
+
+// parses everything after the name of a function or method: type params, params, return type and body
+func (p *Parser) function(name scanner.Token) (*ast.FnStmt, error) {
+	fn, err := p.signature(name)
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.LEFT_BRACE, "expect function body after function declaration")
+	if err != nil {
+		return nil, err
+	}
+	fn.Body, err = p.blockStmt()
+	if err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
 
+// a function without its body, which is all interfaces declare for their methods
+func (p *Parser) signature(name scanner.Token) (*ast.FnStmt, error) {
+	typeParams, err := p.typeParams()
+	if err != nil {
+		return nil, err
+	}
 	_, err = p.consume(scanner.LEFT_PAREN, "expect '(' after function identifier")
 	if err != nil {
 		return nil, err
@@ -236,14 +357,12 @@ func (p *Parser) fnDeclaration() (ast.Stmt, error) {
 			if err != nil {
 				return nil, err
 			}
-			isPointer := false
-			if p.match(scanner.STAR) {
-				isPointer = true
+			paramType, err := p.parseType("expected type after parameter name")
+			if err != nil {
+				return nil, err
 			}
-			paramType := p.advance()
-			// TODO more error checking here
 
-			params = append(params, ast.Param{Name: paramName, Type: ast.Type{Token: paramType, IsPointer: isPointer}})
+			params = append(params, ast.Param{Name: paramName, Type: paramType})
 			if !p.match(scanner.COMMA) || p.isAtEnd() {
 				break
 			}
@@ -258,47 +377,254 @@ func (p *Parser) fnDeclaration() (ast.Stmt, error) {
 		return nil, err
 	}
 
-	isPointer := false
-	if p.match(scanner.STAR) {
-		isPointer = true
+	var returnType ast.Type = ast.Type{Token: scanner.Token{Type: scanner.TYPE, Lexeme: "void"}}
+	if !p.check(scanner.LEFT_BRACE) && !p.check(scanner.SEMI_COLON) {
+		returnType, err = p.parseType("expected valid type for function return")
+		if err != nil {
+			return nil, err
+		}
+		// T? means the function returns either a T or an error
+		if p.match(scanner.QUESTION) {
+			payload := returnType
+			returnType = ast.Type{Token: p.prev(), IsErrorUnion: true, Elem: &payload}
+		}
 	}
-	var returnType scanner.Token = scanner.Token{Type: scanner.TYPE, Lexeme: "void"}
-	if !p.check(scanner.LEFT_BRACE) {
-		returnType, err = p.consumeType("expected valid type for function return")
+	return &ast.FnStmt{Name: name, TypeParams: typeParams, Params: params, Return: returnType}, nil
+}
+
+// the [T, U] after the name of a generic function or struct. [T: Shape + Named] bounds T by interfaces
+func (p *Parser) typeParams() ([]ast.TypeParam, error) {
+	typeParams := make([]ast.TypeParam, 0)
+	if !p.match(scanner.LEFT_BRACK) {
+		return typeParams, nil
+	}
+	for {
+		name, err := p.consume(scanner.IDENTIFIER, "expect type parameter name")
 		if err != nil {
 			return nil, err
 		}
+		typeParam := ast.TypeParam{Name: name}
+		if p.match(scanner.COLON) {
+			typeParam.Bounds, err = p.interfaceList("expect interface name after ':'", scanner.PLUS)
+			if err != nil {
+				return nil, err
+			}
+		}
+		typeParams = append(typeParams, typeParam)
+		if !p.match(scanner.COMMA) {
+			break
+		}
 	}
-	_, err = p.consume(scanner.LEFT_BRACE, "expect function body after function declaration")
+	_, err := p.consume(scanner.RIGHT_BRACK, "expect ']' after type parameters")
 	if err != nil {
 		return nil, err
 	}
-	body, err := p.blockStmt()
+	return typeParams, nil
+}
+
+// fn(x number) number { ... } is a function value that can see the locals around it
+func function(p *Parser) (ast.Expr, error) {
+	keyword := p.prev()
+	fn, err := p.function(keyword)
+	if err != nil {
+		return nil, err
+	}
+	if len(fn.TypeParams) > 0 {
+		return nil, p.errorAtCurrent("anonymous functions can't have type parameters")
+	}
+	return &ast.FunctionExpr{Keyword: keyword, Params: fn.Params, Body: fn.Body, Return: fn.Return}, nil
+}
+
+func (p *Parser) structDeclaration() (ast.Stmt, error) {
+	name, err := p.consume(scanner.IDENTIFIER, "expect struct name")
+	if err != nil {
+		return nil, err
+	}
+	typeParams, err := p.typeParams()
+	if err != nil {
+		return nil, err
+	}
+	// struct Circle: Shape, Named { ... } asks for the interfaces to be checked where the struct is declared
+	implements := make([]scanner.Token, 0)
+	if p.match(scanner.COLON) {
+		implements, err = p.interfaceList("expect interface name after ':'", scanner.COMMA)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(scanner.LEFT_BRACE, "expect '{' after struct name")
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]ast.Field, 0)
+	methods := make([]ast.Method, 0)
+	memberNames := make(map[string]bool)
+	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
+		// fields and methods are private by default
+		isPublic := p.match(scanner.PUB)
+		memberName, err := p.consume(scanner.IDENTIFIER, "expect field or method name")
+		if err != nil {
+			return nil, err
+		}
+		if memberNames[memberName.Lexeme] {
+			return nil, p.errorAtCurrent(fmt.Sprintf("duplicate member '%s' in struct '%s'", memberName.Lexeme, name.Lexeme))
+		}
+		memberNames[memberName.Lexeme] = true
+
+		if p.check(scanner.LEFT_PAREN) {
+			method, err := p.function(memberName)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, ast.Method{Fn: method, IsPublic: isPublic})
+			continue
+		}
+
+		fieldType, err := p.parseType("expect type after field name")
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.consume(scanner.SEMI_COLON, "expect ';' after struct field")
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, ast.Field{Name: memberName, Type: fieldType, IsPublic: isPublic})
+	}
+
+	_, err = p.consume(scanner.RIGHT_BRACE, "expect '}' to close struct declaration")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.StructStmt{Name: name, TypeParams: typeParams, Implements: implements, Fields: fields, Methods: methods}, nil
+}
+
+func (p *Parser) interfaceList(msg string, separator scanner.TokenType) ([]scanner.Token, error) {
+	interfaces := make([]scanner.Token, 0)
+	for {
+		name, err := p.consume(scanner.IDENTIFIER, msg)
+		if err != nil {
+			return nil, err
+		}
+		interfaces = append(interfaces, name)
+		if !p.match(separator) {
+			return interfaces, nil
+		}
+	}
+}
+
+// interface Shape { area() number; } - the methods a struct needs for its values to be used as a Shape
+func (p *Parser) interfaceDeclaration() (ast.Stmt, error) {
+	name, err := p.consume(scanner.IDENTIFIER, "expect interface name")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.LEFT_BRACE, "expect '{' after interface name")
+	if err != nil {
+		return nil, err
+	}
+
+	methods := make([]*ast.FnStmt, 0)
+	methodNames := make(map[string]bool)
+	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
+		methodName, err := p.consume(scanner.IDENTIFIER, "expect method name")
+		if err != nil {
+			return nil, err
+		}
+		if methodNames[methodName.Lexeme] {
+			return nil, p.errorAtCurrent(fmt.Sprintf("duplicate method '%s' in interface '%s'", methodName.Lexeme, name.Lexeme))
+		}
+		methodNames[methodName.Lexeme] = true
+		method, err := p.signature(methodName)
+		if err != nil {
+			return nil, err
+		}
+		if len(method.TypeParams) > 0 {
+			return nil, p.errorAtCurrent("interface methods can't have type parameters")
+		}
+		_, err = p.consume(scanner.SEMI_COLON, "expect ';' after interface method")
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	_, err = p.consume(scanner.RIGHT_BRACE, "expect '}' to close interface declaration")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.InterfaceStmt{Name: name, Methods: methods}, nil
+}
+
+// enum Color { RED GREEN BLUE } - variants are always caps and only reachable as Color.RED
+func (p *Parser) enumDeclaration() (ast.Stmt, error) {
+	name, err := p.consume(scanner.IDENTIFIER, "expect enum name")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.LEFT_BRACE, "expect '{' after enum name")
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([]scanner.Token, 0)
+	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
+		variant, err := p.consume(scanner.IDENTIFIER, "expect enum variant")
+		if err != nil {
+			return nil, err
+		}
+		if strings.ToUpper(variant.Lexeme) != variant.Lexeme {
+			return nil, p.errorAtCurrent(fmt.Sprintf("enum variant '%s' must be all caps", variant.Lexeme))
+		}
+		for _, existing := range variants {
+			if existing.Lexeme == variant.Lexeme {
+				return nil, p.errorAtCurrent(fmt.Sprintf("duplicate variant '%s' in enum '%s'", variant.Lexeme, name.Lexeme))
+			}
+		}
+		variants = append(variants, variant)
+		p.match(scanner.COMMA)
+	}
+
+	_, err = p.consume(scanner.RIGHT_BRACE, "expect '}' to close enum declaration")
 	if err != nil {
 		return nil, err
 	}
-	return &ast.FnStmt{Name: name, Params: params, Body: body, Return: ast.Type{Token: returnType, IsPointer: isPointer}}, nil
+	return &ast.EnumStmt{Name: name, Variants: variants}, nil
 }
 
 func (p *Parser) returnStmt() (ast.Stmt, error) {
+	keyword := p.prev()
 	if p.match(scanner.SEMI_COLON) {
-		return &ast.ReturnStmt{Expression: nil}, nil
+		return &ast.ReturnStmt{Keyword: keyword, Expression: nil}, nil
 	}
 	expr, err := p.expression()
 	if err != nil {
 		return nil, err
 	}
+	// return a, b; returns the tuple (a, b)
+	if p.check(scanner.COMMA) {
+		elements := []ast.Expr{expr}
+		for p.match(scanner.COMMA) {
+			element, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		expr = &ast.TupleExpr{Paren: keyword, Elements: elements}
+	}
 
 	_, err = p.consume(scanner.SEMI_COLON, "expect ';' after return statement")
 	if err != nil {
 		return nil, err
 	}
 
-	return &ast.ReturnStmt{Expression: expr}, nil
+	return &ast.ReturnStmt{Keyword: keyword, Expression: expr}, nil
 }
 
 func (p *Parser) ifStmt() (ast.Stmt, error) {
-	ifCondition, err := p.expression()
+	keyword := p.prev()
+	ifCondition, err := p.condition()
 	if err != nil {
 		return nil, err
 	}
@@ -311,10 +637,12 @@ func (p *Parser) ifStmt() (ast.Stmt, error) {
 		return nil, err
 	}
 
+	elifKeywords := make([]scanner.Token, 0)
 	elifConditions := make([]ast.Expr, 0)
 	elifBlocks := make([]ast.Stmt, 0)
 	for p.match(scanner.ELIF) {
-		elifCondition, err := p.expression()
+		elifKeywords = append(elifKeywords, p.prev())
+		elifCondition, err := p.condition()
 		if err != nil {
 			return nil, err
 		}
@@ -348,7 +676,129 @@ func (p *Parser) ifStmt() (ast.Stmt, error) {
 	// 	ElifBlocks:     elifBlocks,
 	// 	ElseBlock:      elseBlock,
 	// }
-	return &ast.IfStmt{IfCondition: ifCondition, IfBlock: ifBlock, ElifConditions: elifConditions, ElifBlocks: elifBlocks, ElseBlock: elseBlock}, nil
+	return &ast.IfStmt{Keyword: keyword, IfCondition: ifCondition, IfBlock: ifBlock, ElifKeywords: elifKeywords, ElifConditions: elifConditions, ElifBlocks: elifBlocks, ElseBlock: elseBlock}, nil
+}
+
+func (p *Parser) whileStmt() (ast.Stmt, error) {
+	keyword := p.prev()
+	condition, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.LEFT_BRACE, "expect block after while condition")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.loopBody()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.WhileStmt{Keyword: keyword, Condition: condition, Body: body}, nil
+}
+
+func (p *Parser) forStmt() (ast.Stmt, error) {
+	keyword := p.prev()
+	if !p.match(scanner.LET, scanner.VAR) {
+		return p.forRangeStmt()
+	}
+	initializer, err := p.varDeclaration()
+	if err != nil {
+		return nil, err
+	}
+
+	var condition ast.Expr = nil
+	if !p.check(scanner.SEMI_COLON) {
+		condition, err = p.condition()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(scanner.SEMI_COLON, "expect ';' after for loop condition")
+	if err != nil {
+		return nil, err
+	}
+
+	var increment ast.Expr = nil
+	if !p.check(scanner.LEFT_BRACE) {
+		increment, err = p.condition()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(scanner.LEFT_BRACE, "expect block after for loop clauses")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.loopBody()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ForStmt{Keyword: keyword, Initializer: initializer, Condition: condition, Increment: increment, Body: body}, nil
+}
+
+// for i in start..end { } - end is exclusive
+func (p *Parser) forRangeStmt() (ast.Stmt, error) {
+	variable, err := p.consume(scanner.IDENTIFIER, "expect 'let' or loop variable after 'for'")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.IN, "expect 'in' after loop variable")
+	if err != nil {
+		return nil, err
+	}
+	start, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.DOTDOT, "expect '..' in range")
+	if err != nil {
+		return nil, err
+	}
+	end, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.LEFT_BRACE, "expect block after range")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.loopBody()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ForRangeStmt{Variable: variable, Start: start, End: end, Body: body}, nil
+}
+
+// parses the block of a loop while tracking nesting so break/continue can be validated
+func (p *Parser) loopBody() (ast.Stmt, error) {
+	p.loopDepth++
+	body, err := p.blockStmt()
+	p.loopDepth--
+	return body, err
+}
+
+func (p *Parser) breakStmt() (ast.Stmt, error) {
+	keyword := p.prev()
+	if p.loopDepth == 0 {
+		return nil, p.errorAtCurrent("'break' used outside of a loop")
+	}
+	_, err := p.consume(scanner.SEMI_COLON, "expect ';' after 'break'")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.BreakStmt{Keyword: keyword}, nil
+}
+
+func (p *Parser) continueStmt() (ast.Stmt, error) {
+	keyword := p.prev()
+	if p.loopDepth == 0 {
+		return nil, p.errorAtCurrent("'continue' used outside of a loop")
+	}
+	_, err := p.consume(scanner.SEMI_COLON, "expect ';' after 'continue'")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ContinueStmt{Keyword: keyword}, nil
 }
 
 // de-sugar the if elseif else into just if else
@@ -370,7 +820,9 @@ func (p *Parser) blockStmt() (ast.Stmt, error) {
 	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
 		stmt, err := p.declaration()
 		if err != nil {
-			return nil, err
+			// carry on with the next statement so one mistake doesn't take the rest of the block with it
+			p.synchronize()
+			continue
 		}
 		stmts = append(stmts, stmt)
 	}
@@ -381,36 +833,166 @@ func (p *Parser) blockStmt() (ast.Stmt, error) {
 	return &ast.BlockStmt{Body: stmts}, nil
 }
 
-func (p *Parser) expressionStmt() (ast.Stmt, error) {
-	expr, err := p.expression()
+// match x { 1, 2 => ..., 3..10 => { ... }, Color.RED => ..., _ => ... } runs the first arm with a matching pattern
+func (p *Parser) matchStmt() (ast.Stmt, error) {
+	keyword := p.prev()
+	subject, arms, err := p.matchArms(false)
 	if err != nil {
 		return nil, err
 	}
-	_, err = p.consume(scanner.SEMI_COLON, "Expected ';'")
+	return &ast.MatchStmt{Keyword: keyword, Subject: subject, Arms: arms}, nil
+}
+
+// as an expression every arm is a value: let name = match n { 1 => "one", _ => "many" };
+func matchExpression(p *Parser) (ast.Expr, error) {
+	keyword := p.prev()
+	subject, arms, err := p.matchArms(true)
 	if err != nil {
 		return nil, err
 	}
-	return &ast.ExpressionStmt{Expression: expr}, nil
-}
-
-func (p *Parser) expression() (ast.Expr, error) {
-	return p.prattParse(PREC_ASSIGNMENT)
+	return &ast.MatchExpr{Keyword: keyword, Subject: subject, Arms: arms}, nil
 }
 
-func grouping(p *Parser) (ast.Expr, error) {
-	expr, err := p.expression()
+func (p *Parser) matchArms(isExpression bool) (ast.Expr, []ast.MatchArm, error) {
+	subject, err := p.condition()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	_, err = p.consume(scanner.LEFT_BRACE, "expect '{' after match subject")
+	if err != nil {
+		return nil, nil, err
 	}
-	p.consume(scanner.RIGHT_PAREN, "expected closing paren")
-	return &ast.GroupingExpr{Expression: expr}, nil
-}
 
-func call(p *Parser, left ast.Expr) (ast.Expr, error) {
-	args := make([]ast.Expr, 0)
-	for !p.check(scanner.RIGHT_PAREN) && !p.isAtEnd() {
-		expr, err := p.expression()
-		if err != nil {
+	arms := make([]ast.MatchArm, 0)
+	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
+		arm := ast.MatchArm{}
+		for {
+			pattern, err := p.pattern()
+			if err != nil {
+				return nil, nil, err
+			}
+			arm.Patterns = append(arm.Patterns, pattern)
+			if !p.match(scanner.COMMA) {
+				break
+			}
+		}
+		_, err = p.consume(scanner.FAT_ARROW, "expect '=>' after match pattern")
+		if err != nil {
+			return nil, nil, err
+		}
+		// arms with a block body don't need a comma after them
+		isBlock := !isExpression && p.match(scanner.LEFT_BRACE)
+		if isBlock {
+			arm.Body, err = p.blockStmt()
+		} else if isExpression {
+			arm.Value, err = p.nestedExpression()
+		} else {
+			var expr ast.Expr
+			expr, err = p.nestedExpression()
+			arm.Body = &ast.ExpressionStmt{Expression: expr}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		arms = append(arms, arm)
+		if !p.match(scanner.COMMA) && !isBlock && !p.check(scanner.RIGHT_BRACE) {
+			return nil, nil, p.errorAtCurrent("expect ',' after match arm")
+		}
+	}
+	_, err = p.consume(scanner.RIGHT_BRACE, "expect '}' to close match")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(arms) == 0 {
+		return nil, nil, p.errorAtCurrent("match needs at least one arm")
+	}
+	return subject, arms, nil
+}
+
+// a constant, a start..end range of them with the end excluded, or _ which matches anything
+func (p *Parser) pattern() (ast.Pattern, error) {
+	token := p.peek()
+	if token.Type == scanner.IDENTIFIER && token.Lexeme == "_" {
+		p.advance()
+		return ast.Pattern{Token: token, IsWildcard: true}, nil
+	}
+	value, err := p.condition()
+	if err != nil {
+		return ast.Pattern{}, err
+	}
+	pattern := ast.Pattern{Token: token, Value: value}
+	if p.match(scanner.DOTDOT) {
+		pattern.End, err = p.condition()
+		if err != nil {
+			return ast.Pattern{}, err
+		}
+	}
+	return pattern, nil
+}
+
+func (p *Parser) expressionStmt() (ast.Stmt, error) {
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.SEMI_COLON, "Expected ';'")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ExpressionStmt{Expression: expr}, nil
+}
+
+func (p *Parser) expression() (ast.Expr, error) {
+	return p.prattParse(PREC_NONE)
+}
+
+// parses the expression in front of a block (if, while, for) where '{' starts the block instead of a struct literal
+func (p *Parser) condition() (ast.Expr, error) {
+	prevNoStructLiteral := p.noStructLiteral
+	p.noStructLiteral = true
+	expr, err := p.expression()
+	p.noStructLiteral = prevNoStructLiteral
+	return expr, err
+}
+
+// parses an expression that is delimited by brackets so struct literals are unambiguous again
+func (p *Parser) nestedExpression() (ast.Expr, error) {
+	prevNoStructLiteral := p.noStructLiteral
+	p.noStructLiteral = false
+	expr, err := p.expression()
+	p.noStructLiteral = prevNoStructLiteral
+	return expr, err
+}
+
+func grouping(p *Parser) (ast.Expr, error) {
+	paren := p.prev()
+	expr, err := p.nestedExpression()
+	if err != nil {
+		return nil, err
+	}
+	// (a, b) is a tuple
+	if p.check(scanner.COMMA) {
+		elements := []ast.Expr{expr}
+		for p.match(scanner.COMMA) {
+			element, err := p.nestedExpression()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		p.consume(scanner.RIGHT_PAREN, "expected closing paren")
+		return &ast.TupleExpr{Paren: paren, Elements: elements}, nil
+	}
+	p.consume(scanner.RIGHT_PAREN, "expected closing paren")
+	return &ast.GroupingExpr{Expression: expr}, nil
+}
+
+func call(p *Parser, left ast.Expr) (ast.Expr, error) {
+	paren := p.prev()
+	args := make([]ast.Expr, 0)
+	for !p.check(scanner.RIGHT_PAREN) && !p.isAtEnd() {
+		expr, err := p.nestedExpression()
+		if err != nil {
 			return nil, err
 		}
 		p.match(scanner.COMMA)
@@ -418,14 +1000,21 @@ func call(p *Parser, left ast.Expr) (ast.Expr, error) {
 		args = append(args, expr)
 	}
 	p.consume(scanner.RIGHT_PAREN, "expected closing paren")
-	return &ast.CallExpr{Callee: left, Args: args}, nil
+	return &ast.CallExpr{Callee: left, Paren: paren, Args: args}, nil
 }
 
 func number(p *Parser) (ast.Expr, error) {
-	value := p.prev().Literal.(float64)
-	return &ast.NumberExpr{
+	value := p.prev().Literal.(constant.Value)
+	expr := &ast.NumberExpr{
 		Value: value,
-	}, nil
+	}
+	// 255u8 has the type of its suffix, a plain 255 takes the type of wherever it's used
+	if p.match(scanner.SUFFIX) {
+		suffix := p.prev()
+		suffix.Type = scanner.TYPE
+		expr.Suffix = &ast.Type{Token: suffix}
+	}
+	return expr, nil
 }
 
 func string_(p *Parser) (ast.Expr, error) {
@@ -435,6 +1024,31 @@ func string_(p *Parser) (ast.Expr, error) {
 	}, nil
 }
 
+// "a {x} b {y}" arrives as STRING_PART("a "), x, STRING_PART(" b "), y, STRING("")
+func interpolation(p *Parser) (ast.Expr, error) {
+	start := p.prev()
+	strings := []string{start.Literal.(string)}
+	values := make([]ast.Expr, 0)
+	for {
+		value, err := p.nestedExpression()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.match(scanner.STRING_PART) {
+			strings = append(strings, p.prev().Literal.(string))
+			continue
+		}
+		end, err := p.consume(scanner.STRING, "expect '}' after expression in string interpolation")
+		if err != nil {
+			return nil, err
+		}
+		strings = append(strings, end.Literal.(string))
+		break
+	}
+	return &ast.InterpolationExpr{Start: start, Strings: strings, Values: values}, nil
+}
+
 func char(p *Parser) (ast.Expr, error) {
 	value := p.prev().Literal.(int8)
 	return &ast.CharExpr{
@@ -442,6 +1056,10 @@ func char(p *Parser) (ast.Expr, error) {
 	}, nil
 }
 
+func nil_(p *Parser) (ast.Expr, error) {
+	return &ast.NilExpr{Keyword: p.prev()}, nil
+}
+
 func boolean(p *Parser) (ast.Expr, error) {
 	value, err := strconv.ParseBool(p.prev().Lexeme)
 	if err != nil {
@@ -454,9 +1072,263 @@ func boolean(p *Parser) (ast.Expr, error) {
 
 func variable(p *Parser) (ast.Expr, error) {
 	token := p.prev()
+	if p.check(scanner.LEFT_BRACE) && !p.noStructLiteral {
+		return structLiteral(p, token, nil)
+	}
+	if p.check(scanner.LEFT_BRACK) && !p.noStructLiteral && p.isTypeArgsBefore(scanner.LEFT_BRACE) {
+		typeArgs, err := p.typeArgs()
+		if err != nil {
+			return nil, err
+		}
+		return structLiteral(p, token, typeArgs)
+	}
+	// id[i32](5), a single name like id[Point](p) could just as well be an index and is left to the generator
+	if p.check(scanner.LEFT_BRACK) && p.isTypeArgsBefore(scanner.LEFT_PAREN) && p.isClearlyTypeArgs() {
+		typeArgs, err := p.typeArgs()
+		if err != nil {
+			return nil, err
+		}
+		p.advance()
+		expr, err := call(p, &ast.IdentifierExpr{Value: token})
+		if err != nil {
+			return nil, err
+		}
+		expr.(*ast.CallExpr).TypeArgs = typeArgs
+		return expr, nil
+	}
 	return &ast.IdentifierExpr{Value: token}, nil
 }
 
+func (p *Parser) typeArgs() ([]ast.Type, error) {
+	p.advance()
+	typeArgs := make([]ast.Type, 0)
+	for {
+		typeArg, err := p.parseType("expect type argument")
+		if err != nil {
+			return nil, err
+		}
+		typeArgs = append(typeArgs, typeArg)
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+	_, err := p.consume(scanner.RIGHT_BRACK, "expect ']' after type arguments")
+	return typeArgs, err
+}
+
+// looks past the '[' after a name for Pair[i32] { ... } or id[i32](...), which would otherwise be indexing. only
+// tokens that can make up types are allowed between the brackets, so a[i] { still parses as an index
+func (p *Parser) isTypeArgsBefore(next scanner.TokenType) bool {
+	depth := 0
+	for i := p.current; i < len(p.tokens); i++ {
+		switch p.tokens[i].Type {
+		case scanner.LEFT_BRACK:
+			depth++
+		case scanner.RIGHT_BRACK:
+			depth--
+			if depth == 0 {
+				return i+1 < len(p.tokens) && p.tokens[i+1].Type == next
+			}
+		case scanner.TYPE, scanner.IDENTIFIER, scanner.NUMBER, scanner.STAR, scanner.QUESTION, scanner.COMMA,
+			scanner.FN, scanner.LEFT_PAREN, scanner.RIGHT_PAREN:
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// fns[i](x) is a call of an element, the brackets only hold types when there is something in them an index can't
+// have, like a builtin type or more than one thing
+func (p *Parser) isClearlyTypeArgs() bool {
+	depth, parens := 0, 0
+	for i := p.current; i < len(p.tokens); i++ {
+		switch p.tokens[i].Type {
+		case scanner.TYPE, scanner.FN, scanner.QUESTION:
+			return true
+		case scanner.COMMA:
+			if depth == 1 && parens == 0 {
+				return true
+			}
+		case scanner.LEFT_PAREN:
+			parens++
+		case scanner.RIGHT_PAREN:
+			parens--
+		case scanner.LEFT_BRACK:
+			depth++
+		case scanner.RIGHT_BRACK:
+			depth--
+			if depth == 0 {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// Name { field: value, ... } - fields that are left out are zero initialized. generic structs can be given
+// their type arguments like Pair[i32] { ... } instead of having them inferred
+func structLiteral(p *Parser, name scanner.Token, typeArgs []ast.Type) (ast.Expr, error) {
+	name.Type = scanner.TYPE
+	p.advance()
+
+	fields := make([]scanner.Token, 0)
+	values := make([]ast.Expr, 0)
+	for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
+		field, err := p.consume(scanner.IDENTIFIER, "expect field name in struct literal")
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.consume(scanner.COLON, "expect ':' after field name in struct literal")
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.nestedExpression()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		values = append(values, value)
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+	_, err := p.consume(scanner.RIGHT_BRACE, "expect '}' to close struct literal")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.StructLiteralExpr{Name: name, TypeArgs: typeArgs, Fields: fields, Values: values}, nil
+}
+
+func arrayLiteral(p *Parser) (ast.Expr, error) {
+	bracket := p.prev()
+	elements := make([]ast.Expr, 0)
+	for !p.check(scanner.RIGHT_BRACK) && !p.isAtEnd() {
+		element, err := p.nestedExpression()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+	_, err := p.consume(scanner.RIGHT_BRACK, "expect ']' to close array literal")
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) == 0 {
+		return nil, p.errorAtCurrent("array literal needs at least one element")
+	}
+	return &ast.ArrayLiteralExpr{Bracket: bracket, Elements: elements}, nil
+}
+
+// a[i] or a sub-slice a[start..end] where either bound can be left out
+func index(p *Parser, left ast.Expr) (ast.Expr, error) {
+	bracket := p.prev()
+	var start ast.Expr = nil
+	var err error
+	if !p.check(scanner.DOTDOT) {
+		start, err = p.nestedExpression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.match(scanner.DOTDOT) {
+		var end ast.Expr = nil
+		if !p.check(scanner.RIGHT_BRACK) {
+			end, err = p.nestedExpression()
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = p.consume(scanner.RIGHT_BRACK, "expect ']' after slice range")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.SliceExpr{Object: left, Bracket: bracket, Start: start, End: end}, nil
+	}
+	_, err = p.consume(scanner.RIGHT_BRACK, "expect ']' after index")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.IndexExpr{Object: left, Bracket: bracket, Index: start}, nil
+}
+
+func dot(p *Parser, left ast.Expr) (ast.Expr, error) {
+	// p.? unwraps a nullable pointer, aborting at runtime if it's nil
+	if p.match(scanner.QUESTION) {
+		return &ast.UnwrapExpr{Expression: left, Operator: p.prev()}, nil
+	}
+	// t.0 is the first element of a tuple. t.0.1 is scanned as the number 0.1, so it's split into both indices
+	if p.match(scanner.NUMBER) {
+		number := p.prev()
+		indices := strings.Split(number.Lexeme, ".")
+		for _, index := range indices {
+			if len(indices) > 2 || index == "" || strings.Trim(index, "0123456789") != "" {
+				return nil, p.errorAtCurrent(fmt.Sprintf("tuple index must be a whole number, got '%s'", number.Lexeme))
+			}
+		}
+		for _, index := range indices {
+			left = &ast.GetExpr{Object: left, Name: scanner.Token{Type: scanner.NUMBER, Lexeme: index, Line: number.Line}}
+		}
+		return left, nil
+	}
+	name, err := p.consume(scanner.IDENTIFIER, "expect field name or tuple index after '.'")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.GetExpr{Object: left, Name: name}, nil
+}
+
+// error(Enum.VARIANT) - the error value returned from a function with a T? return type
+func errorValue(p *Parser) (ast.Expr, error) {
+	keyword := p.prev()
+	_, err := p.consume(scanner.LEFT_PAREN, "expect '(' after 'error'")
+	if err != nil {
+		return nil, err
+	}
+	code, err := p.nestedExpression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(scanner.RIGHT_PAREN, "expect ')' after error code")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ErrorExpr{Keyword: keyword, Code: code}, nil
+}
+
+// try f() - unwraps the value or returns the error from the enclosing function
+func try(p *Parser) (ast.Expr, error) {
+	keyword := p.prev()
+	expr, err := p.prattParse(p.tokenPrecedence(keyword.Type))
+	if err != nil {
+		return nil, err
+	}
+	return &ast.TryExpr{Keyword: keyword, Expression: expr}, nil
+}
+
+// f() catch fallback - unwraps the value or evaluates to fallback on error
+func catch(p *Parser, left ast.Expr) (ast.Expr, error) {
+	keyword := p.prev()
+	fallback, err := p.prattParse(p.tokenPrecedence(keyword.Type))
+	if err != nil {
+		return nil, err
+	}
+	return &ast.CatchExpr{Expression: left, Keyword: keyword, Fallback: fallback}, nil
+}
+
+// x as i32 - explicit conversion between numeric types
+func cast(p *Parser, left ast.Expr) (ast.Expr, error) {
+	keyword := p.prev()
+	type_, err := p.parseType("expect type after 'as'")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.CastExpr{Expression: left, Keyword: keyword, Type: type_}, nil
+}
+
 func binary(p *Parser, left ast.Expr) (ast.Expr, error) {
 	operator := p.prev()
 	operatorPrecedence := p.tokenPrecedence(operator.Type)
@@ -471,13 +1343,88 @@ func binary(p *Parser, left ast.Expr) (ast.Expr, error) {
 	}, nil
 }
 
-func unary(p *Parser) (ast.Expr, error) {
+func logical(p *Parser, left ast.Expr) (ast.Expr, error) {
 	operator := p.prev()
 	operatorPrecedence := p.tokenPrecedence(operator.Type)
 	right, err := p.prattParse(operatorPrecedence)
 	if err != nil {
 		return nil, err
 	}
+	return &ast.LogicalExpr{
+		Left:     left,
+		Operator: operator,
+		Right:    right,
+	}, nil
+}
+
+func assign(p *Parser, left ast.Expr) (ast.Expr, error) {
+	operator := p.prev()
+	if !isAssignable(left) {
+		return nil, p.errorAtCurrent("invalid assignment target")
+	}
+	// parsing the right side one level below assignment makes it right associative: a = b = c is a = (b = c)
+	value, err := p.prattParse(PREC_ASSIGNMENT - 1)
+	if err != nil {
+		return nil, err
+	}
+	return &ast.AssignExpr{
+		Target:   left,
+		Operator: operator,
+		Value:    value,
+	}, nil
+}
+
+// ++x and --x evaluate to the updated value
+func prefixIncrement(p *Parser) (ast.Expr, error) {
+	operator := p.prev()
+	target, err := p.prattParse(PREC_UNARY)
+	if err != nil {
+		return nil, err
+	}
+	if !isAssignable(target) {
+		return nil, p.errorAtCurrent(fmt.Sprintf("invalid target for '%s'", operator.Lexeme))
+	}
+	return &ast.IncrementExpr{
+		Target:   target,
+		Operator: operator,
+		IsPrefix: true,
+	}, nil
+}
+
+// x++ and x-- evaluate to the value from before the update
+func postfixIncrement(p *Parser, left ast.Expr) (ast.Expr, error) {
+	operator := p.prev()
+	if !isAssignable(left) {
+		return nil, p.errorAtCurrent(fmt.Sprintf("invalid target for '%s'", operator.Lexeme))
+	}
+	return &ast.IncrementExpr{
+		Target:   left,
+		Operator: operator,
+		IsPrefix: false,
+	}, nil
+}
+
+// only variables, struct fields, array elements and dereferenced pointers can be assigned to
+func isAssignable(expr ast.Expr) bool {
+	switch target := expr.(type) {
+	case *ast.IdentifierExpr, *ast.GetExpr, *ast.IndexExpr:
+		return true
+	case *ast.GroupingExpr:
+		return isAssignable(target.Expression)
+	case *ast.UnaryExpr:
+		return target.Operator.Type == scanner.STAR
+	default:
+		return false
+	}
+}
+
+func unary(p *Parser) (ast.Expr, error) {
+	operator := p.prev()
+	// tokens like - and & are also infix operators, so their table precedence is the infix one
+	right, err := p.prattParse(PREC_UNARY)
+	if err != nil {
+		return nil, err
+	}
 	return &ast.UnaryExpr{
 		Operator: operator,
 		Right:    right,
@@ -488,7 +1435,12 @@ func (p *Parser) prattParse(precedence Precedence) (ast.Expr, error) {
 	token := p.advance()
 	prefixFn := p.parseTable.GetRule(token.Type).PrefixRule
 	if prefixFn == nil {
-		return nil, p.errorAtCurrent(fmt.Sprintf("no prefix parse expression for lexeme '%s'", string(token.Lexeme)))
+		err := p.errorAtCurrent(fmt.Sprintf("no prefix parse expression for lexeme '%s'", string(token.Lexeme)))
+		if token.Type == scanner.RIGHT_BRACE {
+			// left for the block it closes, see synchronize
+			p.current--
+		}
+		return nil, err
 	}
 
 	left, err := prefixFn(p)
@@ -514,15 +1466,32 @@ func (p *Parser) advance() scanner.Token {
 	p.current++
 	return previous
 }
+// skips to the start of the next statement. whole blocks are skipped, and the '}' closing the block the error is
+// in is left for it so the rest of the file isn't read as if it had ended
 func (p *Parser) synchronize() {
-	p.advance()
+	depth := 0
 	for !p.isAtEnd() {
-		if p.prev().Type == scanner.SEMI_COLON {
-			return
-		}
 		switch p.peek().Type {
-		case scanner.LET | scanner.FN | scanner.RETURN | scanner.IF:
-			return
+		case scanner.LEFT_BRACE:
+			depth++
+		case scanner.RIGHT_BRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+			if depth == 0 {
+				p.advance()
+				return
+			}
+		case scanner.SEMI_COLON:
+			if depth == 0 {
+				p.advance()
+				return
+			}
+		case scanner.LET, scanner.FN, scanner.RETURN, scanner.IF:
+			if depth == 0 {
+				return
+			}
 		}
 		p.advance()
 	}
@@ -554,6 +1523,130 @@ func (p *Parser) consume(type_ scanner.TokenType, message string) (scanner.Token
 	return scanner.Token{}, p.errorAtCurrent(message)
 }
 
+// parses a full type annotation such as number, *Point, [3]number or []number
+func (p *Parser) parseType(msg string) (ast.Type, error) {
+	// ?*T is a pointer that can be nil, plain *T never is
+	isNullable := p.match(scanner.QUESTION)
+	if isNullable && !p.check(scanner.STAR) {
+		return ast.Type{}, p.errorAtCurrent("only pointer types can be nullable, expect '*' after '?'")
+	}
+	isPointer := p.match(scanner.STAR)
+	if p.match(scanner.FN) {
+		return p.functionType(isPointer, isNullable)
+	}
+	// (i32, i32) is a tuple type
+	if p.match(scanner.LEFT_PAREN) {
+		paren := p.prev()
+		elems := make([]ast.Type, 0)
+		for {
+			elem, err := p.parseType(msg)
+			if err != nil {
+				return ast.Type{}, err
+			}
+			elems = append(elems, elem)
+			if !p.match(scanner.COMMA) {
+				break
+			}
+		}
+		_, err := p.consume(scanner.RIGHT_PAREN, "expect ')' after tuple element types")
+		if err != nil {
+			return ast.Type{}, err
+		}
+		if len(elems) < 2 {
+			return ast.Type{}, p.errorAtCurrent("tuples need at least two elements")
+		}
+		return ast.Type{Token: paren, IsPointer: isPointer, IsNullable: isNullable, IsTuple: true, Elems: elems}, nil
+	}
+	if p.match(scanner.LEFT_BRACK) {
+		bracket := p.prev()
+		if p.match(scanner.RIGHT_BRACK) {
+			elem, err := p.parseType(msg)
+			if err != nil {
+				return ast.Type{}, err
+			}
+			return ast.Type{Token: bracket, IsPointer: isPointer, IsNullable: isNullable, IsSlice: true, Elem: &elem}, nil
+		}
+		lengthToken, err := p.consume(scanner.NUMBER, "expect array length")
+		if err != nil {
+			return ast.Type{}, err
+		}
+		length, isWhole := constant.Int64Val(constant.ToInt(lengthToken.Literal.(constant.Value)))
+		if !isWhole || length < 1 {
+			return ast.Type{}, p.errorAtCurrent(fmt.Sprintf("array length must be a positive whole number, got '%s'", lengthToken.Lexeme))
+		}
+		_, err = p.consume(scanner.RIGHT_BRACK, "expect ']' after array length")
+		if err != nil {
+			return ast.Type{}, err
+		}
+		elem, err := p.parseType(msg)
+		if err != nil {
+			return ast.Type{}, err
+		}
+		return ast.Type{Token: bracket, IsPointer: isPointer, IsNullable: isNullable, IsArray: true, Length: int(length), Elem: &elem}, nil
+	}
+	typeToken, err := p.consumeType(msg)
+	if err != nil {
+		return ast.Type{}, err
+	}
+	// Pair[number] is a generic struct given its type arguments
+	var typeArgs []ast.Type
+	if p.match(scanner.LEFT_BRACK) {
+		for {
+			typeArg, err := p.parseType("expect type argument")
+			if err != nil {
+				return ast.Type{}, err
+			}
+			typeArgs = append(typeArgs, typeArg)
+			if !p.match(scanner.COMMA) {
+				break
+			}
+		}
+		_, err = p.consume(scanner.RIGHT_BRACK, "expect ']' after type arguments")
+		if err != nil {
+			return ast.Type{}, err
+		}
+	}
+	return ast.Type{Token: typeToken, IsPointer: isPointer, IsNullable: isNullable, TypeArgs: typeArgs}, nil
+}
+
+// fn(number, number) number is the type of function values taking two numbers and returning one.
+// the return type is left out for functions that don't return anything
+func (p *Parser) functionType(isPointer bool, isNullable bool) (ast.Type, error) {
+	keyword := p.prev()
+	_, err := p.consume(scanner.LEFT_PAREN, "expect '(' after 'fn' in function type")
+	if err != nil {
+		return ast.Type{}, err
+	}
+	params := make([]ast.Type, 0)
+	for !p.check(scanner.RIGHT_PAREN) && !p.isAtEnd() {
+		param, err := p.parseType("expect parameter type in function type")
+		if err != nil {
+			return ast.Type{}, err
+		}
+		params = append(params, param)
+		if !p.match(scanner.COMMA) {
+			break
+		}
+	}
+	_, err = p.consume(scanner.RIGHT_PAREN, "expect ')' after parameter types in function type")
+	if err != nil {
+		return ast.Type{}, err
+	}
+
+	returnType := ast.Type{Token: scanner.Token{Type: scanner.TYPE, Lexeme: "void"}}
+	if p.check(scanner.TYPE) || p.check(scanner.IDENTIFIER) || p.check(scanner.STAR) || p.check(scanner.LEFT_BRACK) || p.check(scanner.FN) || p.check(scanner.QUESTION) || p.check(scanner.LEFT_PAREN) {
+		returnType, err = p.parseType("expect return type in function type")
+		if err != nil {
+			return ast.Type{}, err
+		}
+		if p.match(scanner.QUESTION) {
+			payload := returnType
+			returnType = ast.Type{Token: p.prev(), IsErrorUnion: true, Elem: &payload}
+		}
+	}
+	return ast.Type{Token: keyword, IsPointer: isPointer, IsNullable: isNullable, IsFunction: true, Params: params, Return: &returnType}, nil
+}
+
 // seperate helper function because need to handle primite + user defined types
 func (p *Parser) consumeType(msg string) (scanner.Token, error) {
 	if p.match(scanner.TYPE) {
@@ -1,8 +1,16 @@
 package scanner
 
 import (
+	"bytes"
 	"fmt"
+	"go/constant"
+	"go/token"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Scanner struct {
@@ -14,6 +22,8 @@ type Scanner struct {
 	keywords map[string]TokenType
 	Errors   []error
 	HadError bool
+	// open braces inside of each string interpolation being scanned, innermost last
+	interpolations []int
 }
 
 func (s *Scanner) Init() {
@@ -25,6 +35,7 @@ func (s *Scanner) Init() {
 	s.keywords = getKeywords()
 	s.Errors = nil
 	s.HadError = false
+	s.interpolations = nil
 }
 
 func NewScanner() *Scanner {
@@ -54,27 +65,48 @@ func (s *Scanner) ReportErrors() {
 
 func getKeywords() map[string]TokenType {
 	return map[string]TokenType{
-		"let":    LET,
-		"true":   TRUE,
-		"false":  FALSE,
-		"fn":     FN,
-		"if":     IF,
-		"elif":   ELIF,
-		"else":   ELSE,
-		"return": RETURN,
-		"number": TYPE,
-		"string": TYPE,
-		"bool":   TYPE,
-		"char":   TYPE,
-		// "nil":    NIL,
+		"let":       LET,
+		"const":     CONST,
+		"var":       VAR,
+		"true":      TRUE,
+		"false":     FALSE,
+		"fn":        FN,
+		"if":        IF,
+		"elif":      ELIF,
+		"else":      ELSE,
+		"return":    RETURN,
+		"while":     WHILE,
+		"for":       FOR,
+		"in":        IN,
+		"break":     BREAK,
+		"continue":  CONTINUE,
+		"and":       AND,
+		"or":        OR,
+		"struct":    STRUCT,
+		"pub":       PUB,
+		"enum":      ENUM,
+		"interface": INTERFACE,
+		"match":     MATCH,
+		"error":     ERROR,
+		"try":       TRY,
+		"catch":     CATCH,
+		"as":        AS,
+		"number":    TYPE,
+		"string":    TYPE,
+		"bool":      TYPE,
+		"char":      TYPE,
+		"i8":        TYPE,
+		"i16":       TYPE,
+		"i32":       TYPE,
+		"i64":       TYPE,
+		"u8":        TYPE,
+		"u16":       TYPE,
+		"u32":       TYPE,
+		"u64":       TYPE,
+		"f32":       TYPE,
+		"f64":       TYPE,
+		"nil":       NIL,
 		// "elif":     ELIF,
-		// "pub":      PUB,
-		// "for":      FOR,
-		// "while":    WHILE,
-		// "struct":   STRUCT,
-		// "enum":     ENUM,
-		// "break":    BREAK,
-		// "continue": CONTINUE,
 		// "import":   IMPORT,
 		// "print":    PRINT,
 	}
@@ -91,6 +123,7 @@ func (s *Scanner) scanTokens() {
 		case '\t', '\r', ' ':
 			continue
 		case '\n':
+			s.unterminatedInterpolation()
 			s.line++
 			continue
 		case '(':
@@ -98,8 +131,23 @@ func (s *Scanner) scanTokens() {
 		case ')':
 			s.addToken(RIGHT_PAREN)
 		case '{':
+			if depth := len(s.interpolations); depth > 0 {
+				s.interpolations[depth-1]++
+			}
 			s.addToken(LEFT_BRACE)
 		case '}':
+			if depth := len(s.interpolations); depth > 0 {
+				if s.interpolations[depth-1] == 0 {
+					// closes the embedded expression, the rest of the string follows
+					s.interpolations = s.interpolations[:depth-1]
+					if s.Tokens[len(s.Tokens)-1].Type == STRING_PART {
+						s.errorAtCurrent("expect an expression between '{' and '}' in string interpolation, use '\\{' for a literal brace")
+					}
+					s.string()
+					continue
+				}
+				s.interpolations[depth-1]--
+			}
 			s.addToken(RIGHT_BRACE)
 		case '[':
 			s.addToken(LEFT_BRACK)
@@ -107,41 +155,62 @@ func (s *Scanner) scanTokens() {
 			s.addToken(RIGHT_BRACK)
 		case ',':
 			s.addToken(COMMA)
+		case ':':
+			s.addToken(COLON)
+		case '?':
+			s.addToken(QUESTION)
 		case '*':
-			s.addToken(STAR)
+			if s.peek() == '=' {
+				s.advance()
+				s.addToken(STAR_ASSIGN)
+			} else {
+				s.addToken(STAR)
+			}
 		case ';':
 			s.addToken(SEMI_COLON)
 		case '&':
 			s.addToken(ADDRESS)
+		case '|':
+			s.addToken(PIPE)
+		case '^':
+			s.addToken(CARET)
+		case '~':
+			s.addToken(TILDE)
 		case '<':
-			if s.peek() == '=' {
+			if s.peek() == '<' {
+				s.advance()
+				s.addToken(SHIFT_LEFT)
+			} else if s.peek() == '=' {
 				s.advance()
 				s.addToken(LESS_EQ)
 			} else {
 				s.addToken(LESS)
 			}
 		case '>':
-			if s.peek() == '=' {
+			if s.peek() == '>' {
+				s.advance()
+				s.addToken(SHIFT_RIGHT)
+			} else if s.peek() == '=' {
 				s.advance()
 				s.addToken(GREATER_EQ)
 			} else {
 				s.addToken(GREATER)
 			}
 		case '.':
-			// if s.peek() == '.' && s.peekNext() == '.' {
-			// 	s.advance()
-			// 	s.advance()
-			// 	s.addToken(DOTDOTDOT)
-			// } else if s.peek() == '.' {
-			// 	s.advance()
-			// 	s.addToken(DOTDOT)
-			// } else {
-			s.addToken(DOT)
-			// }
+			// TODO: DOTDOTDOT once variadics are supported
+			if s.peek() == '.' {
+				s.advance()
+				s.addToken(DOTDOT)
+			} else {
+				s.addToken(DOT)
+			}
 		case '+':
 			if s.peek() == '+' {
 				s.advance()
 				s.addToken(PLUSPLUS)
+			} else if s.peek() == '=' {
+				s.advance()
+				s.addToken(PLUS_ASSIGN)
 			} else {
 				s.addToken(PLUS)
 			}
@@ -149,6 +218,9 @@ func (s *Scanner) scanTokens() {
 			if s.peek() == '-' {
 				s.advance()
 				s.addToken(MINUSMINUS)
+			} else if s.peek() == '=' {
+				s.advance()
+				s.addToken(MINUS_ASSIGN)
 			} else {
 				s.addToken(MINUS)
 			}
@@ -163,6 +235,9 @@ func (s *Scanner) scanTokens() {
 			if s.peek() == '=' {
 				s.advance()
 				s.addToken(EQUAL)
+			} else if s.peek() == '>' {
+				s.advance()
+				s.addToken(FAT_ARROW)
 			} else {
 				s.addToken(ASSIGN)
 			}
@@ -173,13 +248,24 @@ func (s *Scanner) scanTokens() {
 				for s.peek() != '\n' {
 					s.advance()
 				}
+			} else if s.match('=') {
+				s.addToken(SLASH_ASSIGN)
 			} else {
 				s.addToken(SLASH)
 			}
+		case '%':
+			if s.peek() == '=' {
+				s.advance()
+				s.addToken(MOD_ASSIGN)
+			} else {
+				s.addToken(MOD)
+			}
 		case '"':
 			s.string()
 		case '\'':
 			s.char()
+		case '`':
+			s.rawString()
 		default:
 			if isDigit(c) {
 				s.number()
@@ -190,9 +276,18 @@ func (s *Scanner) scanTokens() {
 			}
 		}
 	}
+	s.unterminatedInterpolation()
 	s.addToken(EOF)
 }
 
+// strings can't span lines, so an embedded expression still open at the end of one was never closed
+func (s *Scanner) unterminatedInterpolation() {
+	if len(s.interpolations) > 0 {
+		s.errorAtCurrent("unterminated string interpolation, expect '}' after the embedded expression")
+		s.interpolations = nil
+	}
+}
+
 func (s *Scanner) addToken(type_ TokenType) {
 	s.addTokenWithLiteral(type_, nil)
 }
@@ -203,18 +298,54 @@ func (s *Scanner) addTokenWithLiteral(type_ TokenType, literal LiteralValue) {
 	s.Tokens = append(s.Tokens, *token)
 }
 
+// "a {x} b" is scanned as STRING_PART("a "), the tokens of x, then STRING(" b").
+// string() picks up again after the '}' that ends each embedded expression
 func (s *Scanner) string() {
+	value := make([]byte, 0)
 	for s.peek() != '"' && !s.isAtEnd() {
+		if s.peek() == '\n' && len(s.interpolations) > 0 {
+			// like "a {", the quote meant to end the string opened this one inside of the embedded expression
+			s.unterminatedInterpolation()
+			return
+		}
 		if s.peek() == '\n' {
-			s.errorAtCurrent("multiline strings are not supported")
-			s.advance()
-			break
+			// keep going to the closing quote so the rest of the string isn't scanned as code
+			s.errorAtCurrent("strings can't span lines, use a raw string in backticks instead")
+			s.line++
 		}
 		s.advance()
+		if s.prev() == '{' {
+			s.addTokenWithLiteral(STRING_PART, string(value))
+			s.interpolations = append(s.interpolations, 0)
+			return
+		}
+		if s.prev() == '\\' {
+			value = append(value, s.escape()...)
+		} else {
+			value = append(value, s.prev())
+		}
 	}
 
 	if s.isAtEnd() {
 		s.errorAtCurrent("unterminated string")
+		return
+	}
+	s.advance()
+	s.addTokenWithLiteral(STRING, string(value))
+}
+
+// raw strings between backticks can span lines and are taken as is, without escapes
+func (s *Scanner) rawString() {
+	for s.peek() != '`' && !s.isAtEnd() {
+		if s.peek() == '\n' {
+			s.line++
+		}
+		s.advance()
+	}
+
+	if s.isAtEnd() {
+		s.errorAtCurrent("unterminated raw string")
+		return
 	}
 	s.advance()
 	valueStr := string(s.source[s.start+1 : s.current-1])
@@ -222,39 +353,238 @@ func (s *Scanner) string() {
 }
 
 func (s *Scanner) char() {
-	for s.peek() != '\'' && !s.isAtEnd() {
+	var value []byte
+	switch s.peek() {
+	case '\'':
 		s.advance()
-	}
-	if s.isAtEnd() {
+		s.errorAtCurrent("empty character, use '\\'' for a single quote")
+		return
+	case '\n', 0:
 		s.errorAtCurrent("unterminated character")
+		return
+	case '\\':
+		s.advance()
+		value = s.escape()
+	default:
+		s.advance()
+		value = []byte{s.prev()}
+	}
+	if s.peek() != '\'' {
+		for s.peek() != '\'' && s.peek() != '\n' && !s.isAtEnd() {
+			s.advance()
+		}
+		if s.peek() == '\'' {
+			s.errorAtCurrent("can only specify 1 character inside of single quotes")
+			s.advance()
+		} else {
+			s.errorAtCurrent("unterminated character")
+		}
+		return
 	}
 	s.advance()
-	if s.current-s.start > 3 {
-		s.errorAtCurrent("can only specify 1 character inside of single quotes")
+	if value == nil {
+		// the escape was already reported
+		return
+	}
+	if len(value) != 1 {
+		s.errorAtCurrent(fmt.Sprintf("'%s' doesn't fit in a char, which holds a single byte", s.source[s.start+1:s.current-1]))
+		return
 	}
 
-	valueChar := int8(s.source[s.start+1])
+	valueChar := int8(value[0])
 	s.addTokenWithLiteral(CHAR, valueChar)
 }
 
+// the bytes for the escape sequence that follows a backslash, like \n, \x41 or \u{1F600}
+func (s *Scanner) escape() []byte {
+	escapeStart := s.current - 1
+	if s.isAtEnd() {
+		s.errorAtEscape(escapeStart, "unfinished escape sequence")
+		return nil
+	}
+	s.advance()
+	switch s.prev() {
+	case 'n':
+		return []byte{'\n'}
+	case 't':
+		return []byte{'\t'}
+	case 'r':
+		return []byte{'\r'}
+	case '0':
+		return []byte{0}
+	case '\\', '"', '\'', '{', '}':
+		return []byte{s.prev()}
+	case 'x':
+		// exactly two hex digits for a single byte
+		for i := 0; i < 2; i++ {
+			if !isHexDigit(s.peek()) {
+				s.errorAtEscape(escapeStart, "\\x needs exactly 2 hex digits")
+				return nil
+			}
+			s.advance()
+		}
+		value, _ := strconv.ParseUint(string(s.source[s.current-2:s.current]), 16, 8)
+		return []byte{byte(value)}
+	case 'u':
+		// a unicode code point, stored as utf-8
+		if s.peek() != '{' {
+			s.errorAtEscape(escapeStart, "\\u needs its code point in braces like \\u{1F600}")
+			return nil
+		}
+		s.advance()
+		digitsStart := s.current
+		for isHexDigit(s.peek()) {
+			s.advance()
+		}
+		digits := string(s.source[digitsStart:s.current])
+		if s.peek() != '}' || len(digits) == 0 || len(digits) > 6 {
+			s.errorAtEscape(escapeStart, "\\u needs 1 to 6 hex digits in braces like \\u{1F600}")
+			return nil
+		}
+		s.advance()
+		value, _ := strconv.ParseUint(digits, 16, 32)
+		if value > unicode.MaxRune || (value >= 0xD800 && value <= 0xDFFF) {
+			s.errorAtEscape(escapeStart, fmt.Sprintf("%s is not a valid unicode code point", digits))
+			return nil
+		}
+		return utf8.AppendRune(nil, rune(value))
+	default:
+		s.errorAtEscape(escapeStart, "unknown escape sequence")
+		return nil
+	}
+}
+
+// number literals can be written in hex (0xFF), binary (0b1010) or octal (0o17), split up by
+// underscores (1_000_000), with an exponent (2.5e-3) and with a suffix that gives them a type (255u8)
 func (s *Scanner) number() {
-	for isDigit(s.peek()) {
+	// the first digit was already consumed, scan it again along with the rest
+	s.current--
+	value, ok := s.numberValue()
+	if !ok {
+		// the error was already reported, skip whatever is left of the literal
+		for isAlpha(s.peek()) || isDigit(s.peek()) {
+			s.advance()
+		}
+	}
+	s.addTokenWithLiteral(NUMBER, value)
+	s.numberSuffix()
+}
+
+var numberBases = map[byte]int{'x': 16, 'X': 16, 'b': 2, 'B': 2, 'o': 8, 'O': 8}
+
+// literals are kept exact, so integers are never rounded and only get checked against the type they're used as
+func (s *Scanner) numberValue() (constant.Value, bool) {
+	if base, isPrefixed := numberBases[s.peekNext()]; isPrefixed && s.peek() == '0' {
+		s.advance()
 		s.advance()
+		digits, ok := s.digits(base)
+		if !ok {
+			return constant.MakeUnknown(), false
+		}
+		value, _ := new(big.Int).SetString(digits, base)
+		return constant.Make(value), true
 	}
 
+	text, ok := s.digits(10)
+	if !ok {
+		return constant.MakeUnknown(), false
+	}
+	isInteger := true
 	if s.peek() == '.' && isDigit(s.peekNext()) {
 		s.advance()
-		for isDigit(s.peek()) {
+		fraction, ok := s.digits(10)
+		if !ok {
+			return constant.MakeUnknown(), false
+		}
+		text += "." + fraction
+		isInteger = false
+	}
+	if (s.peek() == 'e' || s.peek() == 'E') && (isDigit(s.peekNext()) || s.peekNext() == '+' || s.peekNext() == '-') {
+		s.advance()
+		text += "e"
+		if s.peek() == '+' || s.peek() == '-' {
 			s.advance()
+			text += string(s.prev())
+		}
+		if !isDigit(s.peek()) {
+			s.errorAtCurrent(fmt.Sprintf("exponent of number literal '%s' has no digits", s.source[s.start:s.current]))
+			return constant.MakeUnknown(), false
 		}
+		exponent, ok := s.digits(10)
+		if !ok {
+			return constant.MakeUnknown(), false
+		}
+		text += exponent
+		isInteger = false
 	}
-	valueStr := s.source[s.start:s.current]
-	value, _ := strconv.ParseFloat(string(valueStr), 64)
-	s.addTokenWithLiteral(NUMBER, value)
+	var value constant.Value
+	if isInteger {
+		// not through MakeFromLiteral, which would read a leading 0 as octal
+		digits, _ := new(big.Int).SetString(text, 10)
+		value = constant.Make(digits)
+	} else {
+		value = constant.MakeFromLiteral(text, token.FLOAT, 0)
+	}
+	if float, _ := constant.Float64Val(value); value.Kind() == constant.Unknown || math.IsInf(float, 0) {
+		s.errorAtCurrent(fmt.Sprintf("number literal '%s' is out of range", s.source[s.start:s.current]))
+		return constant.MakeUnknown(), false
+	}
+	return value, true
+}
+
+var baseNames = map[int]string{2: "binary", 8: "octal", 10: "decimal", 16: "hex"}
+
+// a run of digits in the given base where single underscores may separate digits, returned without the underscores
+func (s *Scanner) digits(base int) (string, bool) {
+	start := s.current
+	for isDigit(s.peek()) || s.peek() == '_' || (base == 16 && isHexDigit(s.peek())) {
+		s.advance()
+	}
+	text := string(s.source[start:s.current])
+	if text == "" {
+		s.errorAtCurrent(fmt.Sprintf("expect %s digits after '%s'", baseNames[base], s.source[s.start:s.current]))
+		return "", false
+	}
+	if text[0] == '_' || text[len(text)-1] == '_' || strings.Contains(text, "__") {
+		s.errorAtCurrent(fmt.Sprintf("'_' in number literal '%s' must be between digits", s.source[s.start:s.current]))
+		return "", false
+	}
+	for _, c := range text {
+		if c != '_' && !strings.ContainsRune("0123456789abcdef"[:base], unicode.ToLower(c)) {
+			s.errorAtCurrent(fmt.Sprintf("invalid digit '%c' in %s literal '%s'", c, baseNames[base], s.source[s.start:s.current]))
+			return "", false
+		}
+	}
+	return strings.ReplaceAll(text, "_", ""), true
+}
+
+// types a number literal can be given with a suffix
+var numberSuffixes = map[string]bool{
+	"i8": true, "i16": true, "i32": true, "i64": true,
+	"u8": true, "u16": true, "u32": true, "u64": true,
+	"f32": true, "f64": true,
+}
+
+// a suffix right after the digits, as in 255u8, is scanned as its own token
+func (s *Scanner) numberSuffix() {
+	if !isAlpha(s.peek()) {
+		return
+	}
+	s.start = s.current
+	for isAlpha(s.peek()) || isDigit(s.peek()) {
+		s.advance()
+	}
+	suffix := string(s.source[s.start:s.current])
+	if !numberSuffixes[suffix] {
+		s.errorAtCurrent(fmt.Sprintf("invalid suffix '%s' on number literal", suffix))
+		return
+	}
+	s.addToken(SUFFIX)
 }
 
 func (s *Scanner) identifier() {
-	for isAlpha(s.peek()) {
+	// digits are allowed after the first character, as in i32 or vec2
+	for isAlpha(s.peek()) || isDigit(s.peek()) {
 		s.advance()
 	}
 
@@ -299,9 +629,17 @@ func (s *Scanner) match(c byte) bool {
 func (s *Scanner) errorAtCurrent(message string) error {
 	err := fmt.Errorf("error: near line [%d]. cause: %s", s.line, message)
 	s.Errors = append(s.Errors, err)
+	s.HadError = true
 	return err
 }
 
+// reports a bad escape sequence along with its text and the column it starts at
+func (s *Scanner) errorAtEscape(escapeStart int, message string) error {
+	lineStart := bytes.LastIndexByte(s.source[:escapeStart], '\n') + 1
+	column := escapeStart - lineStart + 1
+	return s.errorAtCurrent(fmt.Sprintf("invalid escape '%s' at column %d: %s", s.source[escapeStart:s.current], column, message))
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
@@ -313,6 +651,10 @@ func isDigit(c byte) bool {
 	return false
 }
 
+func isHexDigit(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
 func isAlpha(c byte) bool {
 	if c == '_' {
 		return true
@@ -12,48 +12,84 @@ const (
 
 	literal_beg
 	NUMBER
+	SUFFIX // the type after a number literal like u8 in 255u8
 	STRING // maybe can't be primitive type but must be struct with methods
+	// the part of a string before an embedded {expression}
+	STRING_PART
 	CHAR
 	BOOL
 	IDENTIFIER
 	literal_end
 
 	operator_beg
-	LEFT_PAREN  // (
-	RIGHT_PAREN // )
-	LEFT_BRACE  // {
-	RIGHT_BRACE // }
-	LEFT_BRACK  // [
-	RIGHT_BRACK // ]
-	SEMI_COLON  // ;
-	COMMA       // ,
-	PLUS        // +
-	MINUS       // -
-	STAR        // *
-	SLASH       // /
-	BANG        // !
-	ADDRESS     // &
-	ASSIGN      // =
-	DOT         // .
-	PLUSPLUS    // ++
-	MINUSMINUS  // --
-	EQUAL       // ==
-	NOT_EQUAL   // !=
-	LESS        // <
-	GREATER     // >
-	LESS_EQ     // <=
-	GREATER_EQ  // >=
+	LEFT_PAREN   // (
+	RIGHT_PAREN  // )
+	LEFT_BRACE   // {
+	RIGHT_BRACE  // }
+	LEFT_BRACK   // [
+	RIGHT_BRACK  // ]
+	SEMI_COLON   // ;
+	COMMA        // ,
+	COLON        // :
+	QUESTION     // ?
+	PLUS         // +
+	MINUS        // -
+	STAR         // *
+	SLASH        // /
+	MOD          // %
+	BANG         // !
+	ADDRESS      // & - address of as a prefix, bitwise and as an infix
+	ASSIGN       // =
+	FAT_ARROW    // =>
+	DOT          // .
+	DOTDOT       // ..
+	PLUSPLUS     // ++
+	MINUSMINUS   // --
+	PLUS_ASSIGN  // +=
+	MINUS_ASSIGN // -=
+	STAR_ASSIGN  // *=
+	SLASH_ASSIGN // /=
+	MOD_ASSIGN   // %=
+	EQUAL        // ==
+	NOT_EQUAL    // !=
+	LESS         // <
+	GREATER      // >
+	LESS_EQ      // <=
+	GREATER_EQ   // >=
+	PIPE         // |
+	CARET        // ^
+	TILDE        // ~
+	SHIFT_LEFT   // <<
+	SHIFT_RIGHT  // >>
 	operator_end
 
 	keyword_beg
 	TRUE
 	FALSE
 	LET
+	CONST
+	VAR
 	FN
 	RETURN
 	IF
-    ELIF
+	ELIF
 	ELSE
+	WHILE
+	FOR
+	IN
+	BREAK
+	CONTINUE
+	AND
+	OR
+	STRUCT
+	PUB
+	ENUM
+	INTERFACE
+	MATCH
+	ERROR
+	TRY
+	CATCH
+	AS
 	// STRING_TYPE
 	// NUMBER_TYPE
 	// BOOL_TYPE
@@ -66,15 +102,7 @@ const (
 	placeholders_end
 
 	// TODO: implement these more difficult concepts
-	// DOTDOT                       // ..
 	// DOTDOTDOT                    // ...
-	// STRUCT
-	// ENUM
-	// PUB
-	// FOR
-	// WHILE
-	// BREAK
-	// CONTINUE
 	// IMPORT
 	// PRINT
 )
@@ -91,7 +119,7 @@ func NewToken(type_ TokenType, literal LiteralValue, lexeme string, line int) *T
 }
 
 func (t *Token) DebugPrint() {
-	if t.isOneOf(IDENTIFIER, STRING, NUMBER, BOOL, TYPE) {
+	if t.isOneOf(IDENTIFIER, STRING, STRING_PART, NUMBER, BOOL, TYPE) {
 		fmt.Printf("%s (%s)\n", TokenKindString(t), t.Lexeme)
 	} else {
 		fmt.Printf("%s\n", TokenKindString(t))
@@ -112,6 +140,16 @@ var typeKeywords = map[string]bool{
 	"string": true,
 	"bool":   true,
 	"char":   true,
+	"i8":     true,
+	"i16":    true,
+	"i32":    true,
+	"i64":    true,
+	"u8":     true,
+	"u16":    true,
+	"u32":    true,
+	"u64":    true,
+	"f32":    true,
+	"f64":    true,
 	// placeholders - created parser; should not be used from code
 	"void": true,
 	"auto": true,
@@ -127,6 +165,10 @@ func TokenKindString(token *Token) string {
 		return "number"
 	case STRING:
 		return "string"
+	case SUFFIX:
+		return "suffix"
+	case STRING_PART:
+		return "string_part"
 	case BOOL:
 		return "bool"
 	case CHAR:
@@ -147,6 +189,10 @@ func TokenKindString(token *Token) string {
 		return "semi_colon"
 	case COMMA:
 		return "comma"
+	case COLON:
+		return "colon"
+	case QUESTION:
+		return "question"
 	case PLUS:
 		return "plus"
 	case MINUS:
@@ -155,18 +201,34 @@ func TokenKindString(token *Token) string {
 		return "star"
 	case SLASH:
 		return "slash"
+	case MOD:
+		return "mod"
 	case BANG:
 		return "bang"
 	case ADDRESS:
 		return "address"
 	case ASSIGN:
 		return "assign"
+	case FAT_ARROW:
+		return "fat_arrow"
 	case DOT:
 		return "dot"
+	case DOTDOT:
+		return "dotdot"
 	case PLUSPLUS:
 		return "plusplus"
 	case MINUSMINUS:
 		return "minusminus"
+	case PLUS_ASSIGN:
+		return "plus_assign"
+	case MINUS_ASSIGN:
+		return "minus_assign"
+	case STAR_ASSIGN:
+		return "star_assign"
+	case SLASH_ASSIGN:
+		return "slash_assign"
+	case MOD_ASSIGN:
+		return "mod_assign"
 	case EQUAL:
 		return "equal"
 	case NOT_EQUAL:
@@ -179,10 +241,24 @@ func TokenKindString(token *Token) string {
 		return "less_eq"
 	case GREATER_EQ:
 		return "greater_eq"
+	case PIPE:
+		return "pipe"
+	case CARET:
+		return "caret"
+	case TILDE:
+		return "tilde"
+	case SHIFT_LEFT:
+		return "shift_left"
+	case SHIFT_RIGHT:
+		return "shift_right"
 	case IDENTIFIER:
 		return "identifier"
 	case LET:
 		return "let"
+	case CONST:
+		return "const"
+	case VAR:
+		return "var"
 	case TRUE:
 		return "true"
 	case FALSE:
@@ -203,26 +279,42 @@ func TokenKindString(token *Token) string {
 		return "type"
 	case NIL:
 		return "nil"
-		// case DOTDOT:
-		// 	return "dotdot"
+	case WHILE:
+		return "while"
+	case FOR:
+		return "for"
+	case IN:
+		return "in"
+	case BREAK:
+		return "break"
+	case CONTINUE:
+		return "continue"
+	case AND:
+		return "and"
+	case OR:
+		return "or"
+	case STRUCT:
+		return "struct"
+	case PUB:
+		return "pub"
+	case ENUM:
+		return "enum"
+	case INTERFACE:
+		return "interface"
+	case MATCH:
+		return "match"
+	case ERROR:
+		return "error"
+	case TRY:
+		return "try"
+	case CATCH:
+		return "catch"
+	case AS:
+		return "as"
 		// case DOTDOTDOT:
 		// 	return "dotdotdot"
-		// case STRUCT:
-		// 	return "struct"
-		// case ENUM:
-		// 	return "enum"
 		// case ELIF:
 		// 	return "elif"
-		// case PUB:
-		// 	return "pub"
-		// case FOR:
-		// 	return "for"
-		// case WHILE:
-		// 	return "while"
-		// case BREAK:
-		// 	return "break"
-		// case CONTINUE:
-		// 	return "continue"
 		// case IMPORT:
 		// 	return "import"
 		// case PRINT: